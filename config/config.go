@@ -0,0 +1,439 @@
+package config
+
+import (
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type Config struct {
+	Port         string
+	Storage      string
+	Mongo        MongoConfig
+	JWT          JWTConfig
+	BcryptCost   int
+	DeletePolicy DeletePolicyConfig
+	Mail         MailConfig
+	Login        LoginConfig
+	// BootstrapAdmin, when its Email is set, is made sure to exist as an ADMIN
+	// account on start.
+	BootstrapAdmin AdminConfig
+	// PasswordResetTTL is how long a password reset token can be used.
+	PasswordResetTTL time.Duration
+	// EmailVerificationTTL is how long an email verification token can be
+	// used.
+	EmailVerificationTTL time.Duration
+	// TOTPIssuer names the service in authenticator apps.
+	TOTPIssuer string
+	// TrustedProxies lists the addresses or CIDR ranges of the reverse
+	// proxies whose X-Forwarded-For header gives the client address. When
+	// empty, the address of the connection is used and the header ignored.
+	TrustedProxies []string
+	// PublicCatalog lets genres, movies and reviews be read without a token.
+	PublicCatalog bool
+	// ReviewsRequireVerifiedEmail stops accounts from posting reviews until
+	// their email is verified.
+	ReviewsRequireVerifiedEmail bool
+}
+
+type MongoConfig struct {
+	URL      string
+	Database string
+}
+
+type JWTConfig struct {
+	Secret     string
+	Issuer     string
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+	// ChallengeTTL is how long the second sign in step of an account with
+	// two-factor authentication can be completed.
+	ChallengeTTL time.Duration
+	// Audience is the aud claim tokens are issued for and must carry.
+	Audience string
+	// ClockSkew is how far the clocks of the servers issuing and checking a
+	// token may drift apart.
+	ClockSkew time.Duration
+	// Algorithm signs new tokens: HS256 with Secret, or RS256 or EdDSA with
+	// generated keys that are published at /.well-known/jwks.json.
+	Algorithm string
+	// KeyRotation is how long a generated key signs tokens before the next
+	// one takes over.
+	KeyRotation time.Duration
+	// KeyEncryptionKey is a base64 encoded 32 byte key that encrypts the
+	// private halves of generated keys before they are stored.
+	KeyEncryptionKey string
+}
+
+// DeletePolicyConfig sets what happens to dependent documents when a genre or
+// a movie is deleted. Requests can override it with ?policy=.
+type DeletePolicyConfig struct {
+	Genre string
+	Movie string
+}
+
+// AdminConfig describes the first administrator. An existing account with the
+// email is promoted; otherwise one is created with the other fields.
+type AdminConfig struct {
+	Email    string
+	Username string
+	Name     string
+	Password string
+}
+
+// LoginConfig limits how many wrong passwords can be tried. Past a limit the
+// account, or the client address, is locked out for Lockout, doubling with
+// every further failure up to MaxLockout. Failures are forgotten once none
+// happened for MaxLockout.
+type LoginConfig struct {
+	MaxAttempts      int
+	MaxAttemptsPerIP int
+	Lockout          time.Duration
+	MaxLockout       time.Duration
+}
+
+// MailConfig chooses how emails are delivered.
+type MailConfig struct {
+	// Transport is log, which only logs messages, or file, which writes
+	// them to Dir.
+	Transport string
+	Dir       string
+	From      string
+	// ResetURL is the page of the client app that takes a reset token. The
+	// token is added as a token query parameter. When empty, emails only
+	// contain the token.
+	ResetURL string
+	// VerifyURL takes an email verification token the same way. It can point
+	// at the client app or straight at GET /api/v1/users/verify.
+	VerifyURL string
+}
+
+// Mail transports.
+const (
+	MailLog  = "log"
+	MailFile = "file"
+)
+
+// Token signing algorithms.
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+const (
+	StorageMongo  = "mongo"
+	StorageMemory = "memory"
+)
+
+const (
+	// DeleteRestrict refuses to delete while dependent documents exist.
+	DeleteRestrict = "restrict"
+	// DeleteCascade deletes the dependent documents too.
+	DeleteCascade = "cascade"
+	// DeleteReassign moves a genre's movies to the Uncategorized genre.
+	DeleteReassign = "reassign"
+)
+
+// ValidationError lists every problem found in the configuration so they can
+// all be fixed in one go.
+type ValidationError []string
+
+func (e ValidationError) Error() string {
+	return "invalid configuration:\n  " + strings.Join(e, "\n  ")
+}
+
+func Default() *Config {
+	return &Config{
+		Port:    "8000",
+		Storage: StorageMongo,
+		Mongo: MongoConfig{
+			Database: "cluster0",
+		},
+		JWT: JWTConfig{
+			Issuer:       "shive-api",
+			Audience:     "shive-api",
+			ClockSkew:    30 * time.Second,
+			AccessTTL:    24 * time.Hour,
+			RefreshTTL:   168 * time.Hour,
+			ChallengeTTL: 5 * time.Minute,
+			Algorithm:    AlgorithmHS256,
+			KeyRotation:  720 * time.Hour,
+		},
+		BcryptCost: 14,
+		DeletePolicy: DeletePolicyConfig{
+			Genre: DeleteRestrict,
+			Movie: DeleteRestrict,
+		},
+		Login: LoginConfig{
+			MaxAttempts:      5,
+			MaxAttemptsPerIP: 20,
+			Lockout:          time.Minute,
+			MaxLockout:       time.Hour,
+		},
+		Mail: MailConfig{
+			Transport: MailLog,
+			Dir:       "mail",
+			From:      "Shive <no-reply@shive.local>",
+		},
+		TOTPIssuer:           "Shive",
+		PasswordResetTTL:     time.Hour,
+		EmailVerificationTTL: 48 * time.Hour,
+		BootstrapAdmin: AdminConfig{
+			Username: "admin",
+			Name:     "Administrator",
+		},
+	}
+}
+
+// Load builds the configuration from, in increasing order of precedence, the
+// defaults, an optional YAML or TOML file, environment variables (including a
+// .env file when present) and command line flags.
+func Load(args []string) (*Config, error) {
+	if err := godotenv.Load(".env"); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("loading .env: %w", err)
+	}
+
+	fs := flag.NewFlagSet("shive", flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML or TOML config file")
+	port := fs.String("port", "", "port to listen on")
+	storage := fs.String("storage", "", "storage backend: mongo or memory")
+	mongoURL := fs.String("mongodb-url", "", "MongoDB connection string")
+	mongoDatabase := fs.String("mongodb-database", "", "MongoDB database name")
+	bcryptCost := fs.Int("bcrypt-cost", 0, "bcrypt cost used to hash passwords")
+	publicCatalog := fs.Bool("public-catalog", false, "allow reading genres, movies and reviews without a token")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg := Default()
+	var problems ValidationError
+
+	if *configFile != "" {
+		if err := loadFile(*configFile, cfg); err != nil {
+			return nil, err
+		}
+	}
+	problems = append(problems, applyEnv(cfg)...)
+
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "port":
+			cfg.Port = *port
+		case "storage":
+			cfg.Storage = *storage
+		case "mongodb-url":
+			cfg.Mongo.URL = *mongoURL
+		case "mongodb-database":
+			cfg.Mongo.Database = *mongoDatabase
+		case "bcrypt-cost":
+			cfg.BcryptCost = *bcryptCost
+		case "public-catalog":
+			cfg.PublicCatalog = *publicCatalog
+		}
+	})
+
+	problems = append(problems, cfg.validate()...)
+	if len(problems) > 0 {
+		return nil, problems
+	}
+	return cfg, nil
+}
+
+func applyEnv(cfg *Config) (problems []string) {
+	envString := func(key string, target *string) {
+		if value, ok := os.LookupEnv(key); ok && value != "" {
+			*target = value
+		}
+	}
+	envString("PORT", &cfg.Port)
+	envString("STORAGE", &cfg.Storage)
+	envString("MONGODB_URL", &cfg.Mongo.URL)
+	envString("MONGODB_DATABASE", &cfg.Mongo.Database)
+	envString("SECRET_KEY", &cfg.JWT.Secret)
+	envString("JWT_ISSUER", &cfg.JWT.Issuer)
+	envString("JWT_AUDIENCE", &cfg.JWT.Audience)
+	envString("JWT_ALGORITHM", &cfg.JWT.Algorithm)
+	envString("JWT_KEY_ENCRYPTION_KEY", &cfg.JWT.KeyEncryptionKey)
+	envString("TOTP_ISSUER", &cfg.TOTPIssuer)
+	envString("GENRE_DELETE_POLICY", &cfg.DeletePolicy.Genre)
+	envString("MOVIE_DELETE_POLICY", &cfg.DeletePolicy.Movie)
+	envString("MAIL_TRANSPORT", &cfg.Mail.Transport)
+	envString("MAIL_DIR", &cfg.Mail.Dir)
+	envString("MAIL_FROM", &cfg.Mail.From)
+	envString("PASSWORD_RESET_URL", &cfg.Mail.ResetURL)
+	envString("EMAIL_VERIFY_URL", &cfg.Mail.VerifyURL)
+	envString("ADMIN_EMAIL", &cfg.BootstrapAdmin.Email)
+	envString("ADMIN_USERNAME", &cfg.BootstrapAdmin.Username)
+	envString("ADMIN_NAME", &cfg.BootstrapAdmin.Name)
+	envString("ADMIN_PASSWORD", &cfg.BootstrapAdmin.Password)
+
+	envDuration := func(key string, target *time.Duration) {
+		if value := os.Getenv(key); value != "" {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %q is not a duration", key, value))
+				return
+			}
+			*target = d
+		}
+	}
+	envDuration("JWT_ACCESS_TTL", &cfg.JWT.AccessTTL)
+	envDuration("JWT_REFRESH_TTL", &cfg.JWT.RefreshTTL)
+	envDuration("JWT_CHALLENGE_TTL", &cfg.JWT.ChallengeTTL)
+	envDuration("JWT_KEY_ROTATION", &cfg.JWT.KeyRotation)
+	envDuration("JWT_CLOCK_SKEW", &cfg.JWT.ClockSkew)
+	envDuration("PASSWORD_RESET_TTL", &cfg.PasswordResetTTL)
+	envDuration("EMAIL_VERIFICATION_TTL", &cfg.EmailVerificationTTL)
+	envDuration("LOGIN_LOCKOUT", &cfg.Login.Lockout)
+	envDuration("LOGIN_MAX_LOCKOUT", &cfg.Login.MaxLockout)
+
+	envInt := func(key string, target *int) {
+		if value := os.Getenv(key); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %q is not a number", key, value))
+				return
+			}
+			*target = n
+		}
+	}
+	envInt("BCRYPT_COST", &cfg.BcryptCost)
+	envInt("LOGIN_MAX_ATTEMPTS", &cfg.Login.MaxAttempts)
+	envInt("LOGIN_MAX_ATTEMPTS_PER_IP", &cfg.Login.MaxAttemptsPerIP)
+	if value := os.Getenv("TRUSTED_PROXIES"); value != "" {
+		cfg.TrustedProxies = nil
+		for _, proxy := range strings.Split(value, ",") {
+			if proxy = strings.TrimSpace(proxy); proxy != "" {
+				cfg.TrustedProxies = append(cfg.TrustedProxies, proxy)
+			}
+		}
+	}
+	if value := os.Getenv("PUBLIC_CATALOG"); value != "" {
+		public, err := strconv.ParseBool(value)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("PUBLIC_CATALOG: %q is not a boolean", value))
+		} else {
+			cfg.PublicCatalog = public
+		}
+	}
+	if value := os.Getenv("REVIEWS_REQUIRE_VERIFIED_EMAIL"); value != "" {
+		required, err := strconv.ParseBool(value)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("REVIEWS_REQUIRE_VERIFIED_EMAIL: %q is not a boolean", value))
+		} else {
+			cfg.ReviewsRequireVerifiedEmail = required
+		}
+	}
+	return problems
+}
+
+func (cfg *Config) validate() (problems []string) {
+	if port, err := strconv.Atoi(cfg.Port); err != nil || port < 1 || port > 65535 {
+		problems = append(problems, fmt.Sprintf("port: %q is not a valid TCP port", cfg.Port))
+	}
+	switch cfg.Storage {
+	case StorageMongo:
+		if cfg.Mongo.URL == "" {
+			problems = append(problems, "mongodb url is required when storage is mongo (MONGODB_URL)")
+		}
+		if cfg.Mongo.Database == "" {
+			problems = append(problems, "mongodb database name must not be empty")
+		}
+	case StorageMemory:
+	default:
+		problems = append(problems, fmt.Sprintf("storage: %q must be %q or %q", cfg.Storage, StorageMongo, StorageMemory))
+	}
+	switch cfg.JWT.Algorithm {
+	case AlgorithmHS256:
+		if cfg.JWT.Secret == "" {
+			problems = append(problems, "jwt secret is required (SECRET_KEY)")
+		}
+	case AlgorithmRS256, AlgorithmEdDSA:
+		if cfg.JWT.KeyRotation <= 0 {
+			problems = append(problems, "jwt key rotation must be positive")
+		}
+		if cfg.JWT.KeyEncryptionKey == "" {
+			problems = append(problems, fmt.Sprintf("jwt key encryption key is required with %s (JWT_KEY_ENCRYPTION_KEY)", cfg.JWT.Algorithm))
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("jwt algorithm: %q must be %s, %s or %s", cfg.JWT.Algorithm, AlgorithmHS256, AlgorithmRS256, AlgorithmEdDSA))
+	}
+	if cfg.JWT.KeyEncryptionKey != "" {
+		if kek, err := base64.StdEncoding.DecodeString(cfg.JWT.KeyEncryptionKey); err != nil || len(kek) != 32 {
+			problems = append(problems, "jwt key encryption key must be 32 bytes encoded in base64")
+		}
+	}
+	if cfg.JWT.Issuer == "" || cfg.JWT.Audience == "" {
+		problems = append(problems, "jwt issuer and audience must not be empty")
+	}
+	if cfg.JWT.ClockSkew < 0 || cfg.JWT.ClockSkew > 5*time.Minute {
+		problems = append(problems, "jwt clock skew must be between 0 and 5m")
+	}
+	if cfg.JWT.AccessTTL <= 0 {
+		problems = append(problems, "jwt access ttl must be positive")
+	}
+	if cfg.JWT.RefreshTTL <= cfg.JWT.AccessTTL {
+		problems = append(problems, "jwt refresh ttl must be longer than the access ttl")
+	}
+	if cfg.JWT.ChallengeTTL <= 0 {
+		problems = append(problems, "jwt challenge ttl must be positive")
+	}
+	if cfg.TOTPIssuer == "" {
+		problems = append(problems, "totp issuer must not be empty")
+	}
+	switch cfg.DeletePolicy.Genre {
+	case DeleteRestrict, DeleteCascade, DeleteReassign:
+	default:
+		problems = append(problems, fmt.Sprintf("genre delete policy: %q must be restrict, cascade or reassign", cfg.DeletePolicy.Genre))
+	}
+	switch cfg.DeletePolicy.Movie {
+	case DeleteRestrict, DeleteCascade:
+	default:
+		problems = append(problems, fmt.Sprintf("movie delete policy: %q must be restrict or cascade", cfg.DeletePolicy.Movie))
+	}
+	switch cfg.Mail.Transport {
+	case MailLog:
+	case MailFile:
+		if cfg.Mail.Dir == "" {
+			problems = append(problems, "mail directory is required when the mail transport is file (MAIL_DIR)")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("mail transport: %q must be %q or %q", cfg.Mail.Transport, MailLog, MailFile))
+	}
+	if cfg.PasswordResetTTL <= 0 {
+		problems = append(problems, "password reset ttl must be positive")
+	}
+	if cfg.EmailVerificationTTL <= 0 {
+		problems = append(problems, "email verification ttl must be positive")
+	}
+	if cfg.Login.MaxAttempts < 1 || cfg.Login.MaxAttemptsPerIP < 1 {
+		problems = append(problems, "login max attempts must be at least 1")
+	}
+	if cfg.Login.Lockout <= 0 || cfg.Login.MaxLockout < cfg.Login.Lockout {
+		problems = append(problems, "login lockout must be positive and no longer than the max lockout")
+	}
+	for _, proxy := range cfg.TrustedProxies {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				problems = append(problems, fmt.Sprintf("trusted proxies: %q is not an IP address or CIDR range", proxy))
+			}
+		}
+	}
+	if cfg.BootstrapAdmin.Email == "" && cfg.BootstrapAdmin.Password != "" {
+		problems = append(problems, "bootstrap admin password is set without an email (ADMIN_EMAIL)")
+	}
+	if cfg.BcryptCost < bcrypt.MinCost || cfg.BcryptCost > bcrypt.MaxCost {
+		problems = append(problems, fmt.Sprintf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost))
+	}
+	return problems
+}
@@ -0,0 +1,173 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v2"
+)
+
+// fileConfig mirrors Config as it is written in a config file. Every field is
+// optional so a file only needs to list the settings it overrides.
+type fileConfig struct {
+	Port    *int    `yaml:"port" toml:"port"`
+	Storage *string `yaml:"storage" toml:"storage"`
+	Mongo   struct {
+		URL      *string `yaml:"url" toml:"url"`
+		Database *string `yaml:"database" toml:"database"`
+	} `yaml:"mongodb" toml:"mongodb"`
+	JWT struct {
+		Secret           *string `yaml:"secret" toml:"secret"`
+		Issuer           *string `yaml:"issuer" toml:"issuer"`
+		Audience         *string `yaml:"audience" toml:"audience"`
+		ClockSkew        *string `yaml:"clock_skew" toml:"clock_skew"`
+		AccessTTL        *string `yaml:"access_ttl" toml:"access_ttl"`
+		RefreshTTL       *string `yaml:"refresh_ttl" toml:"refresh_ttl"`
+		ChallengeTTL     *string `yaml:"challenge_ttl" toml:"challenge_ttl"`
+		Algorithm        *string `yaml:"algorithm" toml:"algorithm"`
+		KeyRotation      *string `yaml:"key_rotation" toml:"key_rotation"`
+		KeyEncryptionKey *string `yaml:"key_encryption_key" toml:"key_encryption_key"`
+	} `yaml:"jwt" toml:"jwt"`
+	BcryptCost                  *int     `yaml:"bcrypt_cost" toml:"bcrypt_cost"`
+	TOTPIssuer                  *string  `yaml:"totp_issuer" toml:"totp_issuer"`
+	TrustedProxies              []string `yaml:"trusted_proxies" toml:"trusted_proxies"`
+	PublicCatalog               *bool    `yaml:"public_catalog" toml:"public_catalog"`
+	ReviewsRequireVerifiedEmail *bool    `yaml:"reviews_require_verified_email" toml:"reviews_require_verified_email"`
+	DeletePolicy                struct {
+		Genre *string `yaml:"genre" toml:"genre"`
+		Movie *string `yaml:"movie" toml:"movie"`
+	} `yaml:"delete_policy" toml:"delete_policy"`
+	Login struct {
+		MaxAttempts      *int    `yaml:"max_attempts" toml:"max_attempts"`
+		MaxAttemptsPerIP *int    `yaml:"max_attempts_per_ip" toml:"max_attempts_per_ip"`
+		Lockout          *string `yaml:"lockout" toml:"lockout"`
+		MaxLockout       *string `yaml:"max_lockout" toml:"max_lockout"`
+	} `yaml:"login" toml:"login"`
+	Mail struct {
+		Transport *string `yaml:"transport" toml:"transport"`
+		Dir       *string `yaml:"dir" toml:"dir"`
+		From      *string `yaml:"from" toml:"from"`
+		ResetURL  *string `yaml:"reset_url" toml:"reset_url"`
+		VerifyURL *string `yaml:"verify_url" toml:"verify_url"`
+	} `yaml:"mail" toml:"mail"`
+	PasswordResetTTL     *string `yaml:"password_reset_ttl" toml:"password_reset_ttl"`
+	EmailVerificationTTL *string `yaml:"email_verification_ttl" toml:"email_verification_ttl"`
+	BootstrapAdmin       struct {
+		Email    *string `yaml:"email" toml:"email"`
+		Username *string `yaml:"username" toml:"username"`
+		Name     *string `yaml:"name" toml:"name"`
+		Password *string `yaml:"password" toml:"password"`
+	} `yaml:"bootstrap_admin" toml:"bootstrap_admin"`
+}
+
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+
+	var file fileConfig
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(data, &file)
+	case ".toml":
+		err = toml.Unmarshal(data, &file)
+	default:
+		return fmt.Errorf("config file %s: unsupported format, use .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+
+	if file.Port != nil {
+		cfg.Port = strconv.Itoa(*file.Port)
+	}
+	setString(&cfg.Storage, file.Storage)
+	setString(&cfg.Mongo.URL, file.Mongo.URL)
+	setString(&cfg.Mongo.Database, file.Mongo.Database)
+	setString(&cfg.JWT.Secret, file.JWT.Secret)
+	setString(&cfg.JWT.Issuer, file.JWT.Issuer)
+	setString(&cfg.JWT.Audience, file.JWT.Audience)
+	setString(&cfg.JWT.Algorithm, file.JWT.Algorithm)
+	setString(&cfg.JWT.KeyEncryptionKey, file.JWT.KeyEncryptionKey)
+	if err := setDuration(&cfg.JWT.AccessTTL, file.JWT.AccessTTL, "jwt.access_ttl"); err != nil {
+		return err
+	}
+	if err := setDuration(&cfg.JWT.RefreshTTL, file.JWT.RefreshTTL, "jwt.refresh_ttl"); err != nil {
+		return err
+	}
+	if err := setDuration(&cfg.JWT.ChallengeTTL, file.JWT.ChallengeTTL, "jwt.challenge_ttl"); err != nil {
+		return err
+	}
+	if err := setDuration(&cfg.JWT.ClockSkew, file.JWT.ClockSkew, "jwt.clock_skew"); err != nil {
+		return err
+	}
+	if err := setDuration(&cfg.JWT.KeyRotation, file.JWT.KeyRotation, "jwt.key_rotation"); err != nil {
+		return err
+	}
+	if file.BcryptCost != nil {
+		cfg.BcryptCost = *file.BcryptCost
+	}
+	setString(&cfg.TOTPIssuer, file.TOTPIssuer)
+	if file.TrustedProxies != nil {
+		cfg.TrustedProxies = file.TrustedProxies
+	}
+	if file.PublicCatalog != nil {
+		cfg.PublicCatalog = *file.PublicCatalog
+	}
+	if file.ReviewsRequireVerifiedEmail != nil {
+		cfg.ReviewsRequireVerifiedEmail = *file.ReviewsRequireVerifiedEmail
+	}
+	setString(&cfg.DeletePolicy.Genre, file.DeletePolicy.Genre)
+	setString(&cfg.DeletePolicy.Movie, file.DeletePolicy.Movie)
+	if file.Login.MaxAttempts != nil {
+		cfg.Login.MaxAttempts = *file.Login.MaxAttempts
+	}
+	if file.Login.MaxAttemptsPerIP != nil {
+		cfg.Login.MaxAttemptsPerIP = *file.Login.MaxAttemptsPerIP
+	}
+	if err := setDuration(&cfg.Login.Lockout, file.Login.Lockout, "login.lockout"); err != nil {
+		return err
+	}
+	if err := setDuration(&cfg.Login.MaxLockout, file.Login.MaxLockout, "login.max_lockout"); err != nil {
+		return err
+	}
+	setString(&cfg.Mail.Transport, file.Mail.Transport)
+	setString(&cfg.Mail.Dir, file.Mail.Dir)
+	setString(&cfg.Mail.From, file.Mail.From)
+	setString(&cfg.Mail.ResetURL, file.Mail.ResetURL)
+	setString(&cfg.Mail.VerifyURL, file.Mail.VerifyURL)
+	if err := setDuration(&cfg.PasswordResetTTL, file.PasswordResetTTL, "password_reset_ttl"); err != nil {
+		return err
+	}
+	if err := setDuration(&cfg.EmailVerificationTTL, file.EmailVerificationTTL, "email_verification_ttl"); err != nil {
+		return err
+	}
+	setString(&cfg.BootstrapAdmin.Email, file.BootstrapAdmin.Email)
+	setString(&cfg.BootstrapAdmin.Username, file.BootstrapAdmin.Username)
+	setString(&cfg.BootstrapAdmin.Name, file.BootstrapAdmin.Name)
+	setString(&cfg.BootstrapAdmin.Password, file.BootstrapAdmin.Password)
+	return nil
+}
+
+func setString(target *string, value *string) {
+	if value != nil {
+		*target = *value
+	}
+}
+
+func setDuration(target *time.Duration, value *string, name string) error {
+	if value == nil {
+		return nil
+	}
+	d, err := time.ParseDuration(*value)
+	if err != nil {
+		return fmt.Errorf("config file: %s: %q is not a duration", name, *value)
+	}
+	*target = d
+	return nil
+}
@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	helper "github.com/genesdemon/golang-jwt-project/helpers"
+	"github.com/genesdemon/golang-jwt-project/models"
+	"github.com/genesdemon/golang-jwt-project/repository"
+	"github.com/genesdemon/golang-jwt-project/response"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// For Admin to issue an API key to a service account. The key is only ever
+// returned by this call.
+func CreateAPIKey(keys repository.APIKeyRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		var body models.APIKey
+		if err := c.BindJSON(&body); err != nil {
+			response.Fail(c, response.Invalid(err))
+			return
+		}
+		if validationErr := validate.Struct(&body); validationErr != nil {
+			response.Fail(c, response.Invalid(validationErr))
+			return
+		}
+		if err := unknownPermissions(body.Permissions); err != nil {
+			response.Fail(c, err)
+			return
+		}
+		//nobody can hand out more than they hold
+		for _, permission := range body.Permissions {
+			if !helper.HasPermission(c, permission) {
+				response.Fail(c, response.Forbidden("you cannot grant a permission you do not hold").With("permission", permission))
+				return
+			}
+		}
+		if body.Expires_at != nil && !body.Expires_at.After(time.Now()) {
+			response.Fail(c, response.ValidationFailed("the request is invalid", response.FieldError{
+				Field: "expires_at", Rule: "future", Message: "expires_at must be in the future",
+			}))
+			return
+		}
+
+		key, keyId, hash, err := helper.NewAPIKey()
+		if err != nil {
+			response.Fail(c, err)
+			return
+		}
+		now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		newKey := models.APIKey{
+			Id:          primitive.NewObjectID(),
+			Key_id:      keyId,
+			Name:        body.Name,
+			Hash:        hash,
+			Permissions: append([]string{}, body.Permissions...),
+			Created_by:  c.GetString("uid"),
+			Created_at:  now,
+			Expires_at:  body.Expires_at,
+		}
+		if err := keys.Create(ctx, &newKey); err != nil {
+			response.Fail(c, err)
+			return
+		}
+		response.Success(c, http.StatusCreated, gin.H{"key": key, "api_key": newKey})
+	}
+}
+
+func GetAPIKeys(keys repository.APIKeyRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		filter, req, err := listRequest(c, repository.APIKeyFields)
+		if err != nil {
+			response.Fail(c, err)
+			return
+		}
+		page, err := keys.List(ctx, filter, req)
+		if err != nil {
+			response.Fail(c, err)
+			return
+		}
+		response.Success(c, http.StatusOK, pageBody("api_key_items", page))
+	}
+}
+
+// For Admin to revoke an API key. The key stays listed with its revoked_at.
+func RevokeAPIKey(keys repository.APIKeyRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		key, err := keys.Revoke(ctx, c.Param("key_id"))
+		if err != nil {
+			response.Fail(c, lookupError(err, "API key not found"))
+			return
+		}
+		response.Success(c, http.StatusOK, key)
+	}
+}
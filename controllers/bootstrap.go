@@ -0,0 +1,56 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/genesdemon/golang-jwt-project/config"
+	"github.com/genesdemon/golang-jwt-project/models"
+	"github.com/genesdemon/golang-jwt-project/rbac"
+	"github.com/genesdemon/golang-jwt-project/repository"
+)
+
+// BootstrapAdmin makes sure the administrator described by admin exists, so
+// a fresh install has someone who can promote other users. It does nothing
+// when no email is configured.
+func BootstrapAdmin(ctx context.Context, users repository.UserRepository, admin config.AdminConfig) error {
+	if admin.Email == "" {
+		return nil
+	}
+
+	existing, err := users.FindByEmail(ctx, admin.Email)
+	if err == nil {
+		if existing.User_type != nil && *existing.User_type == rbac.Admin {
+			return nil
+		}
+		if _, err := users.SetUserType(ctx, existing.User_id, rbac.Admin, nil); err != nil {
+			return err
+		}
+		log.Printf("promoted %s to %s", admin.Email, rbac.Admin)
+		return nil
+	}
+	if err != repository.ErrNotFound {
+		return err
+	}
+
+	if admin.Password == "" {
+		return fmt.Errorf("no account uses %s, set a password (ADMIN_PASSWORD) to create it", admin.Email)
+	}
+	user := models.User{
+		Email:    &admin.Email,
+		Username: &admin.Username,
+		Name:     &admin.Name,
+		Password: &admin.Password,
+		//the operator chose this address, there is no one to send a link to
+		Email_verified: true,
+	}
+	if validationErr := validate.Struct(&user); validationErr != nil {
+		return validationErr
+	}
+	if _, err := createUser(ctx, users, &user, rbac.Admin); err != nil {
+		return err
+	}
+	log.Printf("created %s account %s", rbac.Admin, admin.Email)
+	return nil
+}
@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/genesdemon/golang-jwt-project/config"
+	"github.com/genesdemon/golang-jwt-project/models"
+	"github.com/genesdemon/golang-jwt-project/repository"
+	"github.com/genesdemon/golang-jwt-project/response"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// uncategorizedGenre receives the movies of a genre deleted with the reassign
+// policy. It is created on first use.
+const uncategorizedGenre = "Uncategorized"
+
+var genreDeletePolicy = config.DeleteRestrict
+var movieDeletePolicy = config.DeleteRestrict
+
+// deletePolicy returns the ?policy= query parameter, or fallback when it is
+// absent, and checks it is one of the allowed policies.
+func deletePolicy(c *gin.Context, fallback string, allowed ...string) (string, error) {
+	policy := c.DefaultQuery("policy", fallback)
+	for _, p := range allowed {
+		if p == policy {
+			return policy, nil
+		}
+	}
+	return "", fmt.Errorf("policy must be one of %v", allowed)
+}
+
+func uncategorizedGenreId(ctx context.Context, genres repository.GenreRepository) (string, error) {
+	genre, err := genres.FindByName(ctx, uncategorizedGenre)
+	if err == nil {
+		return genre.Id.Hex(), nil
+	}
+	if err != repository.ErrNotFound {
+		return "", err
+	}
+
+	name := uncategorizedGenre
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	newGenre := models.Genre{
+		Id:         primitive.NewObjectID(),
+		Name:       &name,
+		Created_at: now,
+		Updated_at: now,
+	}
+	if err := genres.Create(ctx, &newGenre); err != nil {
+		return "", err
+	}
+	return newGenre.Id.Hex(), nil
+}
+
+// applyGenreDeletePolicy deals with the movies of genre before the genre
+// itself is deleted and reports how many movies and reviews were affected.
+func applyGenreDeletePolicy(ctx context.Context, policy string, genre *models.Genre, genres repository.GenreRepository, movies repository.MovieRepository, reviews repository.ReviewRepository) (gin.H, error) {
+	genreId := genre.Id.Hex()
+	affected := gin.H{"movies": int64(0), "reviews": int64(0)}
+
+	switch policy {
+	case config.DeleteRestrict:
+		count, err := movies.CountByGenre(ctx, genreId)
+		if err != nil {
+			return affected, err
+		}
+		if count > 0 {
+			affected["movies"] = count
+			return affected, response.Conflict("Genre still has movies, move them first or use policy=cascade or policy=reassign")
+		}
+	case config.DeleteCascade:
+		inGenre, err := movies.FindByGenres(ctx, []string{genreId}, false)
+		if err != nil {
+			return affected, err
+		}
+		//only movies without another genre are deleted, the rest are untagged
+		var removedReviews int64
+		for _, movie := range inGenre {
+			if len(movie.Genre_ids) > 1 {
+				continue
+			}
+			removed, err := reviews.DeleteByMovie(ctx, movie.Id.Hex())
+			if err != nil {
+				return affected, err
+			}
+			removedReviews += removed
+		}
+		removedMovies, err := movies.DeleteByGenre(ctx, genreId)
+		if err != nil {
+			return affected, err
+		}
+		untagged, err := movies.RemoveGenre(ctx, genreId)
+		if err != nil {
+			return affected, err
+		}
+		affected["movies"], affected["reviews"], affected["untagged"] = removedMovies, removedReviews, untagged
+	case config.DeleteReassign:
+		if strings.EqualFold(stringValue(genre.Name), uncategorizedGenre) {
+			return affected, response.BadRequest("the " + uncategorizedGenre + " genre cannot be deleted with policy=reassign")
+		}
+		targetId, err := uncategorizedGenreId(ctx, genres)
+		if err != nil {
+			return affected, err
+		}
+		moved, err := movies.ReassignGenre(ctx, genreId, targetId)
+		if err != nil {
+			return affected, err
+		}
+		affected["movies"] = moved
+		affected["reassigned_to"] = targetId
+	}
+	return affected, nil
+}
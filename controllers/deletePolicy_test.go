@@ -0,0 +1,271 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/genesdemon/golang-jwt-project/config"
+	"github.com/genesdemon/golang-jwt-project/models"
+	"github.com/genesdemon/golang-jwt-project/pagination"
+	"github.com/genesdemon/golang-jwt-project/query"
+	"github.com/genesdemon/golang-jwt-project/repository"
+	"github.com/genesdemon/golang-jwt-project/response"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// catalog is a genre with one movie only in it, one movie also in another
+// genre, and a review of each.
+type catalog struct {
+	store  *repository.Store
+	genre  *models.Genre
+	only   *models.Movie
+	shared *models.Movie
+}
+
+func newCatalog(t *testing.T) catalog {
+	t.Helper()
+	ctx := context.Background()
+	store := repository.NewMemoryStore()
+	genre := createGenre(t, store, "Horror")
+	other := createGenre(t, store, "Comedy")
+	c := catalog{
+		store:  store,
+		genre:  genre,
+		only:   createMovie(t, store, "Alien", genre.Id.Hex()),
+		shared: createMovie(t, store, "Shaun", genre.Id.Hex(), other.Id.Hex()),
+	}
+	for _, movie := range []*models.Movie{c.only, c.shared} {
+		movieId, reviewer, text, rating := movie.Id.Hex(), "uid-1", "good", 8
+		review := models.Reviews{Id: primitive.NewObjectID(), Movie_id: &movieId, Reviewer_id: &reviewer, Review: &text, Rating: &rating}
+		if err := store.Reviews.Create(ctx, &review); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return c
+}
+
+func createGenre(t *testing.T, store *repository.Store, name string) *models.Genre {
+	t.Helper()
+	genre := models.Genre{Id: primitive.NewObjectID(), Name: &name}
+	if err := store.Genres.Create(context.Background(), &genre); err != nil {
+		t.Fatal(err)
+	}
+	return &genre
+}
+
+func createMovie(t *testing.T, store *repository.Store, name string, genreIds ...string) *models.Movie {
+	t.Helper()
+	movie := models.Movie{Id: primitive.NewObjectID(), Name: &name, Genre_ids: genreIds}
+	if err := store.Movies.Create(context.Background(), &movie); err != nil {
+		t.Fatal(err)
+	}
+	return &movie
+}
+
+func hasGenre(genreIds []string, genreId string) bool {
+	for _, id := range genreIds {
+		if id == genreId {
+			return true
+		}
+	}
+	return false
+}
+
+func countReviews(t *testing.T, store *repository.Store, movie *models.Movie) int64 {
+	t.Helper()
+	count, err := store.Reviews.CountByMovie(context.Background(), movie.Id.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func TestGenreDeletePolicies(t *testing.T) {
+	tests := []struct {
+		name         string
+		policy       string
+		genreName    string
+		wantCode     response.Code
+		wantAffected gin.H
+		check        func(t *testing.T, c catalog)
+	}{
+		{
+			name:         "restrict refuses while the genre has movies",
+			policy:       config.DeleteRestrict,
+			wantCode:     response.CodeConflict,
+			wantAffected: gin.H{"movies": int64(2), "reviews": int64(0)},
+			check: func(t *testing.T, c catalog) {
+				if _, err := c.store.Movies.FindByID(context.Background(), c.only.Id); err != nil {
+					t.Errorf("movie was deleted: %v", err)
+				}
+			},
+		},
+		{
+			name:         "cascade deletes movies only in the genre and untags the rest",
+			policy:       config.DeleteCascade,
+			wantAffected: gin.H{"movies": int64(1), "reviews": int64(1), "untagged": int64(1)},
+			check: func(t *testing.T, c catalog) {
+				if _, err := c.store.Movies.FindByID(context.Background(), c.only.Id); err != repository.ErrNotFound {
+					t.Errorf("movie only in the genre: err = %v, want ErrNotFound", err)
+				}
+				if countReviews(t, c.store, c.only) != 0 {
+					t.Error("reviews of the deleted movie are left")
+				}
+				shared, err := c.store.Movies.FindByID(context.Background(), c.shared.Id)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if hasGenre(shared.Genre_ids, c.genre.Id.Hex()) || len(shared.Genre_ids) != 1 {
+					t.Errorf("shared movie genres = %v", shared.Genre_ids)
+				}
+				if countReviews(t, c.store, c.shared) != 1 {
+					t.Error("reviews of the shared movie were deleted")
+				}
+			},
+		},
+		{
+			name:         "reassign moves movies to Uncategorized",
+			policy:       config.DeleteReassign,
+			wantAffected: gin.H{"movies": int64(2), "reviews": int64(0)},
+			check: func(t *testing.T, c catalog) {
+				uncategorized, err := c.store.Genres.FindByName(context.Background(), uncategorizedGenre)
+				if err != nil {
+					t.Fatal(err)
+				}
+				only, err := c.store.Movies.FindByID(context.Background(), c.only.Id)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(only.Genre_ids) != 1 || only.Genre_ids[0] != uncategorized.Id.Hex() {
+					t.Errorf("movie only in the genre has genres %v", only.Genre_ids)
+				}
+				shared, err := c.store.Movies.FindByID(context.Background(), c.shared.Id)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if hasGenre(shared.Genre_ids, c.genre.Id.Hex()) || hasGenre(shared.Genre_ids, uncategorized.Id.Hex()) {
+					t.Errorf("shared movie has genres %v, want only its other genre", shared.Genre_ids)
+				}
+			},
+		},
+		{
+			name:      "Uncategorized cannot be reassigned to itself",
+			policy:    config.DeleteReassign,
+			genreName: uncategorizedGenre,
+			wantCode:  response.CodeBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newCatalog(t)
+			genre := c.genre
+			if tt.genreName != "" {
+				genre = createGenre(t, c.store, tt.genreName)
+			}
+			affected, err := applyGenreDeletePolicy(context.Background(), tt.policy, genre, c.store.Genres, c.store.Movies, c.store.Reviews)
+			if tt.wantCode != "" {
+				apiErr, ok := err.(*response.Error)
+				if !ok || apiErr.Code != tt.wantCode {
+					t.Fatalf("err = %v, want code %s", err, tt.wantCode)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			for key, want := range tt.wantAffected {
+				if affected[key] != want {
+					t.Errorf("affected[%s] = %v, want %v", key, affected[key], want)
+				}
+			}
+			if tt.check != nil {
+				tt.check(t, c)
+			}
+		})
+	}
+}
+
+func TestMovieDeletePolicies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name        string
+		query       string
+		wantStatus  int
+		wantDeleted bool
+	}{
+		{"restrict by default", "", http.StatusConflict, false},
+		{"restrict", "?policy=restrict", http.StatusConflict, false},
+		{"cascade", "?policy=cascade", http.StatusOK, true},
+		{"reassign is only for genres", "?policy=reassign", http.StatusBadRequest, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newCatalog(t)
+			router := gin.New()
+			router.DELETE("/movies/:movie_id", DeleteMovie(c.store.Movies, c.store.Reviews))
+
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodDelete, "/movies/"+c.only.Id.Hex()+tt.query, nil)
+			router.ServeHTTP(recorder, request)
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, tt.wantStatus, recorder.Body)
+			}
+
+			_, err := c.store.Movies.FindByID(context.Background(), c.only.Id)
+			if deleted := err == repository.ErrNotFound; deleted != tt.wantDeleted {
+				t.Errorf("movie deleted = %v, want %v", deleted, tt.wantDeleted)
+			}
+			wantReviews := int64(1)
+			if tt.wantDeleted {
+				wantReviews = 0
+			}
+			if left := countReviews(t, c.store, c.only); left != wantReviews {
+				t.Errorf("reviews left = %d, want %d", left, wantReviews)
+			}
+			//other movies are never touched
+			if countReviews(t, c.store, c.shared) != 1 {
+				t.Error("reviews of another movie were deleted")
+			}
+		})
+	}
+}
+
+// A missing genre or movie is reported before any policy runs.
+func TestDeleteUnknownMovie(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c := newCatalog(t)
+	router := gin.New()
+	router.DELETE("/movies/:movie_id", DeleteMovie(c.store.Movies, c.store.Reviews))
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodDelete, "/movies/"+primitive.NewObjectID().Hex()+"?policy=cascade", nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d", recorder.Code, http.StatusNotFound)
+	}
+	page, err := c.store.Movies.List(context.Background(), query.Filter{}, pagination.Request{Limit: 10, Sort: query.DefaultSort})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Items) != 2 {
+		t.Errorf("%d movies left, want 2", len(page.Items))
+	}
+}
+
+// A malformed id is a bad request, not a missing movie.
+func TestDeleteMalformedMovieID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c := newCatalog(t)
+	router := gin.New()
+	router.DELETE("/movies/:movie_id", DeleteMovie(c.store.Movies, c.store.Reviews))
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodDelete, "/movies/not-an-id?policy=cascade", nil))
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", recorder.Code, http.StatusBadRequest)
+	}
+	if !strings.Contains(recorder.Body.String(), `"field":"movie_id"`) {
+		t.Errorf("body %s does not name the movie_id field", recorder.Body)
+	}
+}
@@ -0,0 +1,46 @@
+package controllers
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/genesdemon/golang-jwt-project/repository"
+	"github.com/genesdemon/golang-jwt-project/response"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func init() {
+	//report validation errors under the JSON field names clients send
+	validate.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+		switch name {
+		case "-":
+			return ""
+		case "":
+			return f.Name
+		}
+		return name
+	})
+}
+
+// lookupError turns the error of a repository lookup into the error to
+// respond with: a 404 with the given message, or an internal error.
+func lookupError(err error, notFound string) error {
+	if err == repository.ErrNotFound {
+		return response.NotFound(notFound)
+	}
+	return err
+}
+
+// pathID reads the id in the path parameter param. A malformed id is a bad
+// request, not an id that is simply not found.
+func pathID(c *gin.Context, param string) (primitive.ObjectID, error) {
+	id, err := primitive.ObjectIDFromHex(c.Param(param))
+	if err != nil {
+		badId := response.BadRequest(param + " is not a valid id")
+		badId.Details = []response.FieldError{{Field: param, Rule: "objectid", Message: "must be 24 hexadecimal characters"}}
+		return primitive.NilObjectID, badId
+	}
+	return id, nil
+}
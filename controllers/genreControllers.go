@@ -2,252 +2,191 @@ package controllers
 
 import (
 	"context"
-	"log"
 	"net/http"
-	"strconv"
 	"time"
 
-	"github.com/genesdemon/golang-jwt-project/database"
-	helper "github.com/genesdemon/golang-jwt-project/helpers"
+	"github.com/genesdemon/golang-jwt-project/config"
 	"github.com/genesdemon/golang-jwt-project/models"
+	"github.com/genesdemon/golang-jwt-project/repository"
+	"github.com/genesdemon/golang-jwt-project/response"
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
-var genreCollection *mongo.Collection = database.OpenCollection(database.Client, "genre")
-
-func CreateGenre() gin.HandlerFunc {
+func CreateGenre(genres repository.GenreRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if err := helper.CheckUserType(c, "ADMIN"); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		var genre models.Genre
 		defer cancel()
 
 		//validate the request body
 		if err := c.BindJSON(&genre); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"Status":  http.StatusBadRequest,
-				"Message": "error",
-				"Data":    map[string]interface{}{"data": err.Error()}})
+			response.Fail(c, response.Invalid(err))
+			return
+		}
+
+		//use the validator library to validate required fields
+		if validationErr := validate.Struct(&genre); validationErr != nil {
+			response.Fail(c, response.Invalid(validationErr))
 			return
 		}
 
 		//Check to see if name exists
-		regexMatch := bson.M{"$regex": primitive.Regex{Pattern: *genre.Name, Options: "i"}}
-		count, err := genreCollection.CountDocuments(ctx, bson.M{"name": regexMatch})
-		defer cancel()
+		exists, err := genres.NameExists(ctx, *genre.Name)
 		if err != nil {
-			log.Panic(err)
-			c.JSON(http.StatusBadRequest, gin.H{"error": "error occured while checking for the Name"})
-		}
-		if count > 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "this genre name already exists", "count": count})
+			response.Fail(c, err)
 			return
 		}
-
-		//use the validator library to validate required fields
-		if validationErr := validate.Struct(&genre); validationErr != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"Status":  http.StatusBadRequest,
-				"Message": "error",
-				"Data":    map[string]interface{}{"data": validationErr.Error()}})
+		if exists {
+			response.Fail(c, response.Conflict("this genre name already exists"))
 			return
 		}
 
+		now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		newGenre := models.Genre{
-			Id:   primitive.NewObjectID(),
-			Name: genre.Name,
+			Id:         primitive.NewObjectID(),
+			Name:       genre.Name,
+			Created_at: now,
+			Updated_at: now,
 		}
 
-		result, err := genreCollection.InsertOne(ctx, newGenre)
-
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"Status":  http.StatusBadRequest,
-				"Message": "error",
-				"Data":    map[string]interface{}{"data": err.Error()}})
+		if err := genres.Create(ctx, &newGenre); err != nil {
+			response.Fail(c, err)
 			return
 		}
 
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"Status":  http.StatusInternalServerError,
-				"Message": "error",
-				"Data":    map[string]interface{}{"data": err.Error()}})
-			return
-		}
-
-		c.JSON(http.StatusCreated, gin.H{
-			"Status":  http.StatusCreated,
-			"Message": "success",
-			"Data":    map[string]interface{}{"data": result}})
+		response.Success(c, http.StatusCreated, gin.H{"InsertedID": newGenre.Id})
 	}
 }
 
 // To get just one genre
-func GetGenre() gin.HandlerFunc {
+func GetGenre(genres repository.GenreRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		genreId := c.Param("genre_id")
-		var genre models.Genre
 		defer cancel()
 
-		objId, _ := primitive.ObjectIDFromHex(genreId)
+		objId, err := pathID(c, "genre_id")
+		if err != nil {
+			response.Fail(c, err)
+			return
+		}
 
-		err := genreCollection.FindOne(ctx, bson.M{"_id": objId}).Decode(&genre)
+		genre, err := genres.FindByID(ctx, objId)
+		if err == repository.ErrNotFound {
+			response.Fail(c, response.NotFound("Genre with specified ID not found!"))
+			return
+		}
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"Status":  http.StatusInternalServerError,
-				"Message": "error",
-				"Data":    map[string]interface{}{"data": err.Error()}})
+			response.Fail(c, err)
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{
-			"Status":  http.StatusOK,
-			"Message": "success",
-			"Data":    map[string]interface{}{"data": genre}})
+		response.Success(c, http.StatusOK, genre)
 	}
 }
 
 // To fetch all genres
-func GetGenres() gin.HandlerFunc {
+func GetGenres(genres repository.GenreRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-		recordPerPage, err := strconv.Atoi(c.Query("recordPerPage"))
-		if err != nil || recordPerPage < 1 {
-			recordPerPage = 10
-		}
-		page, err1 := strconv.Atoi(c.Query("page"))
-		if err1 != nil || page < 1 {
-			page = 1
-		}
-
-		startIndex := (page - 1) * recordPerPage
-		startIndex, err = strconv.Atoi(c.Query("startIndex"))
-
-		matchStage := bson.D{{Key: "$match", Value: bson.D{{}}}}
-		groupStage := bson.D{{Key: "$group", Value: bson.D{
-			{Key: "_id", Value: bson.D{{Key: "_id", Value: "null"}}},
-			{Key: "total_count", Value: bson.D{{Key: "$sum", Value: 1}}},
-			{Key: "data", Value: bson.D{{Key: "$push", Value: "$$ROOT"}}}}}}
-		projectStage := bson.D{
-			{Key: "$project", Value: bson.D{
-				{Key: "_id", Value: 0},
-				{Key: "total_count", Value: 1},
-				{Key: "genre_items", Value: bson.D{{Key: "$slice", Value: []interface{}{"$data", startIndex, recordPerPage}}}}}}}
-		result, err := genreCollection.Aggregate(ctx, mongo.Pipeline{
-			matchStage, groupStage, projectStage})
 		defer cancel()
+
+		filter, req, err := listRequest(c, repository.GenreFields)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while fetching genres "})
+			response.Fail(c, err)
+			return
 		}
-		var allgenres []bson.M
-		if err = result.All(ctx, &allgenres); err != nil {
-			log.Fatal(err)
+		allgenres, err := genres.List(ctx, filter, req)
+		if err != nil {
+			response.Fail(c, err)
+			return
 		}
-		c.JSON(http.StatusOK, allgenres[0])
+		response.Success(c, http.StatusOK, pageBody("genre_items", allgenres))
 	}
 }
 
 // Edit genre
-func EditGenre() gin.HandlerFunc {
+func EditGenre(genres repository.GenreRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if err := helper.CheckUserType(c, "ADMIN"); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		genreId := c.Param("genre_id")
 		var genre models.Genre
 		defer cancel()
-		objId, _ := primitive.ObjectIDFromHex(genreId)
+		objId, err := pathID(c, "genre_id")
+		if err != nil {
+			response.Fail(c, err)
+			return
+		}
 
 		//validate the request body
 		if err := c.BindJSON(&genre); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"Status":  http.StatusBadRequest,
-				"Message": "error",
-				"Data":    map[string]interface{}{"data": err.Error()}})
+			response.Fail(c, response.Invalid(err))
 			return
 		}
 
 		//use the validator library to validate required fields
 		if validationErr := validate.Struct(&genre); validationErr != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"Status":  http.StatusBadRequest,
-				"Message": "error",
-				"Data":    map[string]interface{}{"data": validationErr.Error()}})
+			response.Fail(c, response.Invalid(validationErr))
 			return
 		}
 
-		update := bson.M{"name": genre.Name}
-		filterByID := bson.M{"_id": bson.M{"$eq": objId}}
-		result, err := genreCollection.UpdateOne(ctx, filterByID, bson.M{"$set": update})
+		updatedGenre, err := genres.Update(ctx, objId, genre.Name)
+		if err == repository.ErrNotFound {
+			response.Fail(c, response.NotFound("Genre with specified ID not found!"))
+			return
+		}
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"Status":  http.StatusInternalServerError,
-				"Message": "error",
-				"Data":    map[string]interface{}{"data": err.Error()}})
-			return
-		}
-		//get updated genre details
-		var updatedGenre models.Genre
-		if result.MatchedCount == 1 {
-			err := genreCollection.FindOne(ctx, filterByID).Decode(&updatedGenre)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"Status":  http.StatusInternalServerError,
-					"Message": "error",
-					"Data":    map[string]interface{}{"data": err.Error()}})
-				return
-			}
-		}
-
-		c.JSON(http.StatusOK, gin.H{
-			"Status":  http.StatusOK,
-			"Message": "success",
-			"Data":    updatedGenre})
+			response.Fail(c, err)
+			return
+		}
+
+		response.Success(c, http.StatusOK, updatedGenre)
 		// "Data":    map[string]interface{}{"data": updatedGenre}})
 	}
 }
 
-func DeleteAGenre() gin.HandlerFunc {
+func DeleteAGenre(genres repository.GenreRepository, movies repository.MovieRepository, reviews repository.ReviewRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		genreId := c.Param("genre_id")
 		defer cancel()
-		objId, _ := primitive.ObjectIDFromHex(genreId)
+		objId, err := pathID(c, "genre_id")
+		if err != nil {
+			response.Fail(c, err)
+			return
+		}
 
-		result, err := genreCollection.DeleteOne(ctx, bson.M{"_id": objId})
+		policy, err := deletePolicy(c, genreDeletePolicy, config.DeleteRestrict, config.DeleteCascade, config.DeleteReassign)
+		if err != nil {
+			response.Fail(c, response.Invalid(err))
+			return
+		}
+
+		genre, err := genres.FindByID(ctx, objId)
+		if err == repository.ErrNotFound {
+			response.Fail(c, response.NotFound("Genre with specified ID not found!"))
+			return
+		}
+		if err != nil {
+			response.Fail(c, err)
+			return
+		}
+
+		affected, err := applyGenreDeletePolicy(ctx, policy, genre, genres, movies, reviews)
+		if apiErr, ok := err.(*response.Error); ok {
+			apiErr.With("policy", policy).With("affected", affected)
+		}
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"Status":  http.StatusInternalServerError,
-				"Message": "error",
-				"Data":    map[string]interface{}{"data": err.Error()}})
+			response.Fail(c, err)
 			return
 		}
 
-		if result.DeletedCount < 1 {
-			c.JSON(http.StatusNotFound,
-				gin.H{
-					" Status":  http.StatusNotFound,
-					" Message": "error",
-					" Data":    map[string]interface{}{"data": "Genre with specified ID not found!"}},
-			)
+		if err := genres.Delete(ctx, objId); err != nil {
+			response.Fail(c, lookupError(err, "Genre with specified ID not found!"))
 			return
 		}
 
-		c.JSON(http.StatusOK,
-			gin.H{
-				"Status":  http.StatusOK,
-				"Message": "success",
-				"Data":    map[string]interface{}{"data": "Genre successfully deleted!"}},
-		)
+		response.Success(c, http.StatusOK, gin.H{
+			"message":  "Genre successfully deleted!",
+			"policy":   policy,
+			"affected": affected})
 	}
 }
@@ -0,0 +1,29 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	helper "github.com/genesdemon/golang-jwt-project/helpers"
+	"github.com/genesdemon/golang-jwt-project/response"
+	"github.com/gin-gonic/gin"
+)
+
+// JWKS publishes the public keys tokens are signed with, in the standard JWK
+// Set format rather than the usual envelope, so other services can verify
+// tokens without sharing a secret.
+func JWKS() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		keys, err := helper.JWKS(ctx)
+		if err != nil {
+			response.Fail(c, err)
+			return
+		}
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, gin.H{"keys": keys})
+	}
+}
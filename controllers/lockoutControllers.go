@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/genesdemon/golang-jwt-project/config"
+	helper "github.com/genesdemon/golang-jwt-project/helpers"
+	"github.com/genesdemon/golang-jwt-project/repository"
+	"github.com/genesdemon/golang-jwt-project/response"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// loginFailed is the only answer to a wrong email or password, so the two
+// cannot be told apart.
+const loginFailed = "email or password is incorrect"
+
+var (
+	accountBackoff = helper.AccountBackoff(config.Default().Login)
+	//client addresses, across every account they try
+	ipLogins = helper.NewThrottle(helper.IPBackoff(config.Default().Login))
+	//emails without an account, locked out like accounts are so a lockout
+	//does not tell that an account exists
+	unknownLogins = helper.NewThrottle(accountBackoff)
+	//compared against when the email has no account, so that takes as long
+	//as a wrong password
+	dummyPasswordHash []byte
+)
+
+func configureLockout(cfg config.LoginConfig) {
+	accountBackoff = helper.AccountBackoff(cfg)
+	ipLogins = helper.NewThrottle(helper.IPBackoff(cfg))
+	unknownLogins = helper.NewThrottle(accountBackoff)
+	resetRequestsByIP = helper.NewThrottle(helper.IPBackoff(cfg))
+	resetRequestsByEmail = helper.NewThrottle(accountBackoff)
+
+	secret := make([]byte, 16)
+	if _, err := rand.Read(secret); err != nil {
+		panic(err)
+	}
+	dummyPasswordHash = []byte(HashPassword(hex.EncodeToString(secret)))
+}
+
+// tooManyLogins refuses a sign in while the account or client is locked out.
+func tooManyLogins(c *gin.Context, wait time.Duration) {
+	retryLater(c, wait, "too many failed sign in attempts, try again later")
+}
+
+// retryLater answers 429 with msg, telling the client when to try again.
+func retryLater(c *gin.Context, wait time.Duration, msg string) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	response.Fail(c, response.TooManyRequests(msg).With("retry_after", seconds))
+}
+
+// failUnknownLogin answers a sign in with an email that has no account the
+// way a wrong password for an account is answered.
+func failUnknownLogin(c *gin.Context, email string, password string, now time.Time) {
+	if wait := unknownLogins.Wait(email, now); wait > 0 {
+		tooManyLogins(c, wait)
+		return
+	}
+	bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+	unknownLogins.Fail(email, now)
+	ipLogins.Fail(c.ClientIP(), now)
+	response.Fail(c, response.Unauthorized(loginFailed))
+}
+
+// failLogin counts a wrong password or code for the account, locks it out
+// once it has had too many in a row and answers with msg.
+func failLogin(ctx context.Context, c *gin.Context, users repository.UserRepository, userId string, now time.Time, msg string) {
+	ipLogins.Fail(c.ClientIP(), now)
+	failures, err := users.RecordFailedLogin(ctx, userId, now, accountBackoff.Window())
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+	if lock := accountBackoff.LockFor(failures); lock > 0 {
+		if err := users.LockUntil(ctx, userId, now.Add(lock)); err != nil {
+			response.Fail(c, err)
+			return
+		}
+	}
+	response.Fail(c, response.Unauthorized(msg))
+}
+
+// For Admin to lift the lockout of an account after failed sign ins
+func UnlockUser(users repository.UserRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		user, err := users.ClearFailedLogins(ctx, c.Param("user_id"))
+		if err != nil {
+			response.Fail(c, lookupError(err, "User with specified ID not found!"))
+			return
+		}
+		response.Success(c, http.StatusOK, user)
+	}
+}
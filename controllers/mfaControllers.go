@@ -0,0 +1,259 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	helper "github.com/genesdemon/golang-jwt-project/helpers"
+	"github.com/genesdemon/golang-jwt-project/models"
+	"github.com/genesdemon/golang-jwt-project/repository"
+	"github.com/genesdemon/golang-jwt-project/response"
+	"github.com/gin-gonic/gin"
+)
+
+// recoveryCodeCount is how many recovery codes enabling two-factor
+// authentication hands out.
+const recoveryCodeCount = 10
+
+var (
+	totpIssuer   = "Shive"
+	challengeTTL = 5 * time.Minute
+)
+
+// signedInUser loads the account behind the access token of the request.
+func signedInUser(ctx context.Context, c *gin.Context, users repository.UserRepository) (*models.User, error) {
+	if c.GetString("uid") == "" {
+		return nil, response.BadRequest("API keys do not belong to an account")
+	}
+	user, err := users.FindByUserID(ctx, c.GetString("uid"))
+	if err != nil {
+		return nil, lookupError(err, "User with specified ID not found!")
+	}
+	return user, nil
+}
+
+// challengeMFA answers the password step of a sign in to an account with
+// two-factor authentication with a challenge for the code step.
+func challengeMFA(c *gin.Context, user *models.User) {
+	challenge, err := helper.GenerateMFAChallenge(user.User_id, user.Token_version)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+	response.Success(c, http.StatusOK, gin.H{
+		"mfa_required": true,
+		"mfa_token":    challenge,
+		"expires_in":   int(challengeTTL.Seconds()),
+	})
+}
+
+// Finish a sign in to an account with two-factor authentication with the
+// challenge from the password step and a TOTP or recovery code
+func LoginMFA(users repository.UserRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		var body struct {
+			Mfa_token     *string `json:"mfa_token" validate:"required"`
+			Code          *string `json:"code" validate:"required_without=Recovery_code"`
+			Recovery_code *string `json:"recovery_code"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			response.Fail(c, response.Invalid(err))
+			return
+		}
+		if validationErr := validate.Struct(&body); validationErr != nil {
+			response.Fail(c, response.Invalid(validationErr))
+			return
+		}
+
+		now := time.Now()
+		if wait := ipLogins.Wait(c.ClientIP(), now); wait > 0 {
+			tooManyLogins(c, wait)
+			return
+		}
+		claims, msg := helper.ValidateMFAChallenge(*body.Mfa_token)
+		if msg != "" {
+			response.Fail(c, response.Unauthorized(msg))
+			return
+		}
+		foundUser, err := helper.CheckTokenVersion(ctx, users, claims.Subject, claims.Version, claims.Family)
+		if err == helper.ErrSessionRevoked {
+			response.Fail(c, response.Unauthorized(err.Error()))
+			return
+		} else if err != nil {
+			response.Fail(c, err)
+			return
+		}
+		if foundUser.Locked_until != nil && now.Before(*foundUser.Locked_until) {
+			tooManyLogins(c, foundUser.Locked_until.Sub(now))
+			return
+		}
+		if !foundUser.Mfa_enabled || foundUser.Totp_secret == nil {
+			response.Fail(c, response.BadRequest("two-factor authentication is not enabled for this account"))
+			return
+		}
+
+		var valid bool
+		if body.Code != nil {
+			step, ok := helper.ValidateTOTP(*foundUser.Totp_secret, *body.Code, now)
+			if ok {
+				//a code that was seen once must not work again
+				valid, err = users.UseTOTPStep(ctx, foundUser.User_id, step)
+			}
+		} else {
+			valid, err = users.UseRecoveryCode(ctx, foundUser.User_id, helper.HashRecoveryCode(*body.Recovery_code))
+		}
+		if err != nil {
+			response.Fail(c, err)
+			return
+		}
+		if !valid {
+			failLogin(ctx, c, users, foundUser.User_id, now, "the code is incorrect")
+			return
+		}
+		completeLogin(ctx, c, users, foundUser)
+	}
+}
+
+// Start enabling two-factor authentication. The secret is returned once, as
+// is and as an otpauth:// URI for authenticator apps, and only takes effect
+// after ConfirmTOTP.
+func EnrolTOTP(users repository.UserRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		user, err := signedInUser(ctx, c, users)
+		if err != nil {
+			response.Fail(c, err)
+			return
+		}
+		if user.Mfa_enabled {
+			response.Fail(c, response.Conflict("two-factor authentication is already enabled"))
+			return
+		}
+
+		secret, err := helper.NewTOTPSecret()
+		if err != nil {
+			response.Fail(c, err)
+			return
+		}
+		err = users.SetTOTPSecret(ctx, user.User_id, secret)
+		if err == repository.ErrNotFound {
+			response.Fail(c, response.Conflict("two-factor authentication is already enabled"))
+			return
+		}
+		if err != nil {
+			response.Fail(c, err)
+			return
+		}
+		response.Success(c, http.StatusOK, gin.H{
+			"secret":      secret,
+			"otpauth_uri": helper.TOTPURI(totpIssuer, *user.Email, secret),
+		})
+	}
+}
+
+// Turn two-factor authentication on with a code from the enrolled
+// authenticator. The recovery codes are returned once and only their hashes
+// are kept.
+func ConfirmTOTP(users repository.UserRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		var body struct {
+			Code *string `json:"code" validate:"required"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			response.Fail(c, response.Invalid(err))
+			return
+		}
+		if validationErr := validate.Struct(&body); validationErr != nil {
+			response.Fail(c, response.Invalid(validationErr))
+			return
+		}
+
+		user, err := signedInUser(ctx, c, users)
+		if err != nil {
+			response.Fail(c, err)
+			return
+		}
+		if user.Mfa_enabled {
+			response.Fail(c, response.Conflict("two-factor authentication is already enabled"))
+			return
+		}
+		if user.Totp_secret == nil {
+			response.Fail(c, response.BadRequest("start the enrolment before confirming it"))
+			return
+		}
+		step, ok := helper.ValidateTOTP(*user.Totp_secret, *body.Code, time.Now())
+		if !ok {
+			response.Fail(c, response.BadRequest("the code is incorrect"))
+			return
+		}
+
+		codes, hashes, err := helper.NewRecoveryCodes(recoveryCodeCount)
+		if err != nil {
+			response.Fail(c, err)
+			return
+		}
+		//a confirmation racing this one may have enabled it since the check
+		err = users.EnableTOTP(ctx, user.User_id, step, hashes)
+		if err == repository.ErrNotFound {
+			response.Fail(c, response.Conflict("two-factor authentication is already enabled"))
+			return
+		}
+		if err != nil {
+			response.Fail(c, err)
+			return
+		}
+		response.Success(c, http.StatusOK, gin.H{
+			"mfa_enabled":    true,
+			"recovery_codes": codes,
+		})
+	}
+}
+
+// Turn two-factor authentication off. The password is asked for again so a
+// stolen access token is not enough.
+func DisableTOTP(users repository.UserRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		var body struct {
+			Password *string `json:"Password" validate:"required"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			response.Fail(c, response.Invalid(err))
+			return
+		}
+		if validationErr := validate.Struct(&body); validationErr != nil {
+			response.Fail(c, response.Invalid(validationErr))
+			return
+		}
+
+		user, err := signedInUser(ctx, c, users)
+		if err != nil {
+			response.Fail(c, err)
+			return
+		}
+		if passwordIsValid, _ := VerifyPassword(*body.Password, *user.Password); !passwordIsValid {
+			response.Fail(c, response.Forbidden("the password is incorrect"))
+			return
+		}
+		if !user.Mfa_enabled {
+			response.Fail(c, response.Conflict("two-factor authentication is not enabled"))
+			return
+		}
+		if err := users.DisableTOTP(ctx, user.User_id); err != nil {
+			response.Fail(c, err)
+			return
+		}
+		response.Success(c, http.StatusOK, "Two-factor authentication has been turned off")
+	}
+}
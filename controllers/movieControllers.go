@@ -2,324 +2,384 @@ package controllers
 
 import (
 	"context"
-	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
-	"github.com/genesdemon/golang-jwt-project/database"
-	helper "github.com/genesdemon/golang-jwt-project/helpers"
+	"github.com/genesdemon/golang-jwt-project/config"
 	"github.com/genesdemon/golang-jwt-project/models"
+	"github.com/genesdemon/golang-jwt-project/repository"
+	"github.com/genesdemon/golang-jwt-project/response"
+	"github.com/genesdemon/golang-jwt-project/search"
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
-var movieCollection *mongo.Collection = database.OpenCollection(database.Client, "movie")
-
-func CreateMovie() gin.HandlerFunc {
+func CreateMovie(movies repository.MovieRepository, genres repository.GenreRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if err := helper.CheckUserType(c, "ADMIN"); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		var movie models.Movie
 		defer cancel()
 
 		//validate the request body
 		if err := c.BindJSON(&movie); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"Status":  http.StatusBadRequest,
-				"Message": "error",
-				"Data":    map[string]interface{}{"data": err.Error()}})
+			response.Fail(c, response.Invalid(err))
 			return
 		}
 
-		//Check to see if name exists
-		regexMatch := bson.M{"$regex": primitive.Regex{Pattern: *movie.Name, Options: "i"}}
-		count, err := movieCollection.CountDocuments(ctx, bson.M{"name": regexMatch})
-		defer cancel()
-		if err != nil {
-			log.Panic(err)
-			c.JSON(http.StatusBadRequest, gin.H{"error": "error occured while checking for the Name"})
+		//use the validator library to validate required fields
+		if validationErr := validate.Struct(&movie); validationErr != nil {
+			response.Fail(c, response.Invalid(validationErr))
+			return
 		}
-		if count > 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "this movie name already exists", "count": count})
+
+		//every genre must refer to an existing genre
+		movie.Genre_ids = uniqueGenreIds(movie.Genre_ids)
+		if missing, err := missingGenre(ctx, genres, movie.Genre_ids); err != nil {
+			response.Fail(c, err)
+			return
+		} else if missing != "" {
+			response.Fail(c, response.ValidationFailed("request validation failed", response.FieldError{
+				Field:   "genre_ids",
+				Rule:    "exists",
+				Message: missing + " does not refer to an existing genre",
+			}))
 			return
 		}
 
-		//use the validator library to validate required fields
-		if validationErr := validate.Struct(&movie); validationErr != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"Status":  http.StatusBadRequest,
-				"Message": "error",
-				"Data":    map[string]interface{}{"data": validationErr.Error()}})
+		//Check to see if name exists
+		exists, err := movies.NameExists(ctx, *movie.Name)
+		if err != nil {
+			response.Fail(c, err)
+			return
+		}
+		if exists {
+			response.Fail(c, response.Conflict("this movie name already exists"))
 			return
 		}
 
+		now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		newMovie := models.Movie{
-			Id:        primitive.NewObjectID(),
-			Name:      movie.Name,
-			Topic:     movie.Topic,
-			Genre_id:  movie.Genre_id,
-			Movie_URL: movie.Movie_URL,
+			Id:         primitive.NewObjectID(),
+			Name:       movie.Name,
+			Topic:      movie.Topic,
+			Genre_ids:  movie.Genre_ids,
+			Movie_URL:  movie.Movie_URL,
+			Created_at: now,
+			Updated_at: now,
+		}
+
+		if err := movies.Create(ctx, &newMovie); err != nil {
+			response.Fail(c, err)
+			return
 		}
 
-		result, err := movieCollection.InsertOne(ctx, newMovie)
+		response.Success(c, http.StatusCreated, gin.H{"InsertedID": newMovie.Id})
+	}
+}
+
+// To get just one movie
+func GetMovie(movies repository.MovieRepository, genres repository.GenreRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
 
+		objId, err := pathID(c, "movie_id")
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"Status":  http.StatusBadRequest,
-				"Message": "error",
-				"Data":    map[string]interface{}{"data": err.Error()}})
+			response.Fail(c, err)
 			return
 		}
 
+		movie, err := movies.FindByID(ctx, objId)
+		if err == repository.ErrNotFound {
+			response.Fail(c, response.NotFound("Movie with specified ID not found!"))
+			return
+		}
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"Status":  http.StatusInternalServerError,
-				"Message": "error",
-				"Data":    map[string]interface{}{"data": err.Error()}})
+			response.Fail(c, err)
+			return
+		}
+
+		if err := embedGenres(ctx, genres, movie); err != nil {
+			response.Fail(c, err)
 			return
 		}
 
-		c.JSON(http.StatusCreated, gin.H{
-			"Status":  http.StatusCreated,
-			"Message": "success",
-			"Data":    map[string]interface{}{"data": result}})
+		response.Success(c, http.StatusOK, movie)
 	}
 }
 
-// To get just one movie
-func GetMovie() gin.HandlerFunc {
+// To get the rating summary of a movie
+func GetMovieRatings(movies repository.MovieRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		movieId := c.Param("movie_id")
-		var movie models.Movie
 		defer cancel()
 
-		objId, _ := primitive.ObjectIDFromHex(movieId)
-
-		err := movieCollection.FindOne(ctx, bson.M{"_id": objId}).Decode(&movie)
+		objId, err := pathID(c, "movie_id")
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"Status":  http.StatusInternalServerError,
-				"Message": "error",
-				"Data":    map[string]interface{}{"data": err.Error()}})
+			response.Fail(c, err)
+			return
+		}
+		movie, err := movies.FindByID(ctx, objId)
+		if err == repository.ErrNotFound {
+			response.Fail(c, response.NotFound("Movie with specified ID not found!"))
+			return
+		}
+		if err != nil {
+			response.Fail(c, err)
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{
-			"Status":  http.StatusOK,
-			"Message": "success",
-			"Data":    map[string]interface{}{"data": movie}})
+		response.Success(c, http.StatusOK, movie.Ratings)
 	}
 }
 
 // To fetch all movies
-func GetMovies() gin.HandlerFunc {
+func GetMovies(movies repository.MovieRepository, genres repository.GenreRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-		recordPerPage, err := strconv.Atoi(c.Query("recordPerPage"))
-		if err != nil || recordPerPage < 1 {
-			recordPerPage = 10
-		}
-		page, err1 := strconv.Atoi(c.Query("page"))
-		if err1 != nil || page < 1 {
-			page = 1
-		}
-
-		startIndex := (page - 1) * recordPerPage
-		startIndex, err = strconv.Atoi(c.Query("startIndex"))
-
-		matchStage := bson.D{{Key: "$match", Value: bson.D{{}}}}
-		groupStage := bson.D{{Key: "$group", Value: bson.D{
-			{Key: "_id", Value: bson.D{{Key: "_id", Value: "null"}}},
-			{Key: "total_count", Value: bson.D{{Key: "$sum", Value: 1}}},
-			{Key: "data", Value: bson.D{{Key: "$push", Value: "$$ROOT"}}}}}}
-		projectStage := bson.D{
-			{Key: "$project", Value: bson.D{
-				{Key: "_id", Value: 0},
-				{Key: "total_count", Value: 1},
-				{Key: "movie_items", Value: bson.D{{Key: "$slice", Value: []interface{}{"$data", startIndex, recordPerPage}}}}}}}
-		result, err := movieCollection.Aggregate(ctx, mongo.Pipeline{
-			matchStage, groupStage, projectStage})
 		defer cancel()
+
+		filter, req, err := listRequest(c, repository.MovieFields)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while fetching movies "})
+			response.Fail(c, err)
+			return
+		}
+		allmovies, err := movies.List(ctx, filter, req)
+		if err == nil {
+			err = embedAllGenres(ctx, genres, allmovies.Items)
 		}
-		var allmovies []bson.M
-		if err = result.All(ctx, &allmovies); err != nil {
-			log.Fatal(err)
+		if err != nil {
+			response.Fail(c, err)
+			return
 		}
-		c.JSON(http.StatusOK, allmovies[0])
+		response.Success(c, http.StatusOK, pageBody("movie_items", allmovies))
 	}
 }
 
 // Edit movie
-func EditMovie() gin.HandlerFunc {
+func EditMovie(movies repository.MovieRepository, genres repository.GenreRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		movieId := c.Param("movie_id")
 		var movie models.Movie
 		defer cancel()
-		objId, _ := primitive.ObjectIDFromHex(movieId)
+		objId, err := pathID(c, "movie_id")
+		if err != nil {
+			response.Fail(c, err)
+			return
+		}
 
 		//validate the request body
 		if err := c.BindJSON(&movie); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"Status":  http.StatusBadRequest,
-				"Message": "error",
-				"Data":    map[string]interface{}{"data": err.Error()}})
+			response.Fail(c, response.Invalid(err))
 			return
 		}
 
 		//use the validator library to validate required fields
 		if validationErr := validate.Struct(&movie); validationErr != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"Status":  http.StatusBadRequest,
-				"Message": "error",
-				"Data":    map[string]interface{}{"data": validationErr.Error()}})
+			response.Fail(c, response.Invalid(validationErr))
 			return
 		}
 
-		update := bson.M{
-			"name":      movie.Name,
-			"topic":     movie.Topic,
-			"genre_id":  movie.Genre_id,
-			"movie_url": movie.Movie_URL}
-		filterByID := bson.M{"_id": bson.M{"$eq": objId}}
-		result, err := movieCollection.UpdateOne(ctx, filterByID, bson.M{"$set": update})
+		//every genre must refer to an existing genre
+		movie.Genre_ids = uniqueGenreIds(movie.Genre_ids)
+		if missing, err := missingGenre(ctx, genres, movie.Genre_ids); err != nil {
+			response.Fail(c, err)
+			return
+		} else if missing != "" {
+			response.Fail(c, response.ValidationFailed("request validation failed", response.FieldError{
+				Field:   "genre_ids",
+				Rule:    "exists",
+				Message: missing + " does not refer to an existing genre",
+			}))
+			return
+		}
+
+		updatedMovie, err := movies.Update(ctx, objId, &movie)
+		if err == repository.ErrNotFound {
+			response.Fail(c, response.NotFound("Movie with specified ID not found!"))
+			return
+		}
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"Status":  http.StatusInternalServerError,
-				"Message": "error",
-				"Data":    map[string]interface{}{"data": err.Error()}})
+			response.Fail(c, err)
 			return
 		}
-		//get updated movie details
-		var updatedMovie models.Movie
-		if result.MatchedCount == 1 {
-			err := movieCollection.FindOne(ctx, filterByID).Decode(&updatedMovie)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"Status":  http.StatusInternalServerError,
-					"Message": "error",
-					"Data":    map[string]interface{}{"data": err.Error()}})
-				return
-			}
+
+		if err := embedGenres(ctx, genres, updatedMovie); err != nil {
+			response.Fail(c, err)
+			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{
-			"Status":  http.StatusOK,
-			"Message": "success",
-			"Data":    updatedMovie})
+		response.Success(c, http.StatusOK, updatedMovie)
 	}
 }
 
-func DeleteMovie() gin.HandlerFunc {
+func DeleteMovie(movies repository.MovieRepository, reviews repository.ReviewRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		movieId := c.Param("movie_id")
 		defer cancel()
-		objId, _ := primitive.ObjectIDFromHex(movieId)
+		objId, err := pathID(c, "movie_id")
+		if err != nil {
+			response.Fail(c, err)
+			return
+		}
 
-		result, err := movieCollection.DeleteOne(ctx, bson.M{"_id": objId})
+		policy, err := deletePolicy(c, movieDeletePolicy, config.DeleteRestrict, config.DeleteCascade)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"Status":  http.StatusInternalServerError,
-				"Message": "error",
-				"Data":    map[string]interface{}{"data": err.Error()}})
+			response.Fail(c, response.Invalid(err))
 			return
 		}
 
-		if result.DeletedCount < 1 {
-			c.JSON(http.StatusNotFound,
-				gin.H{
-					" Status":  http.StatusNotFound,
-					" Message": "error",
-					" Data":    map[string]interface{}{"data": "Movie with specified ID not found!"}},
-			)
+		if _, err := movies.FindByID(ctx, objId); err == repository.ErrNotFound {
+			response.Fail(c, response.NotFound("Movie with specified ID not found!"))
+			return
+		} else if err != nil {
+			response.Fail(c, err)
 			return
 		}
 
-		c.JSON(http.StatusOK,
-			gin.H{
-				"Status":  http.StatusOK,
-				"Message": "success",
-				"Data":    map[string]interface{}{"data": "Movie successfully deleted!"}},
-		)
+		var affected int64
+		switch policy {
+		case config.DeleteRestrict:
+			count, err := reviews.CountByMovie(ctx, movieId)
+			if err != nil {
+				response.Fail(c, err)
+				return
+			}
+			if count > 0 {
+				response.Fail(c, response.Conflict("Movie still has reviews, delete them first or use policy=cascade").
+					With("policy", policy).
+					With("affected", gin.H{"reviews": count}))
+				return
+			}
+		case config.DeleteCascade:
+			affected, err = reviews.DeleteByMovie(ctx, movieId)
+			if err != nil {
+				response.Fail(c, err)
+				return
+			}
+		}
+
+		if err := movies.Delete(ctx, objId); err != nil {
+			response.Fail(c, lookupError(err, "Movie with specified ID not found!"))
+			return
+		}
+
+		response.Success(c, http.StatusOK, gin.H{
+			"message":  "Movie successfully deleted!",
+			"policy":   policy,
+			"affected": gin.H{"reviews": affected}})
 	}
 }
 
-func SearchMovieByQuery() gin.HandlerFunc {
+// Search movies by name, topic and genre name, best matches first
+func SearchMovieByQuery(movies repository.MovieRepository, genres repository.GenreRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var searchmovies []models.Movie
-		queryParam := c.Query("name")
+		queryParam := c.Query("q")
 		if queryParam == "" {
-			log.Println("query is empty")
-			c.Header("Content-Type", "application/json")
-			c.JSON(http.StatusNotFound, gin.H{"Error": "Invalid Search Index"})
-			c.Abort()
+			queryParam = c.Query("name")
+		}
+		terms := search.Tokenize(queryParam)
+		if len(terms) == 0 {
+			response.Fail(c, response.BadRequest("a search query is required"))
 			return
 		}
+		limit, err := strconv.Atoi(c.Query("limit"))
+		if err != nil || limit < 1 || limit > 100 {
+			limit = 20
+		}
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
-		searchquerydb, err := movieCollection.Find(ctx, bson.M{"name": bson.M{"$regex": queryParam}})
+
+		matchedGenres, err := genres.FindByNameTerms(ctx, terms)
 		if err != nil {
-			c.IndentedJSON(404, "something went wrong in fetching the dbquery")
+			response.Fail(c, err)
 			return
 		}
-		err = searchquerydb.All(ctx, &searchmovies)
+		genreIds := []string{}
+		for _, genre := range matchedGenres {
+			genreIds = append(genreIds, genre.Id.Hex())
+		}
+
+		scored, err := movies.Search(ctx, terms, genreIds, limit)
 		if err != nil {
-			log.Println(err)
-			c.IndentedJSON(400, "invalid")
+			response.Fail(c, err)
 			return
 		}
-		defer searchquerydb.Close(ctx)
-		if err := searchquerydb.Err(); err != nil {
-			log.Println(err)
-			c.IndentedJSON(400, "invalid request")
+
+		matched := make([]*models.Movie, len(scored))
+		for i := range scored {
+			matched[i] = &scored[i].Movie
+		}
+		if err := embedGenres(ctx, genres, matched...); err != nil {
+			response.Fail(c, err)
 			return
 		}
-		defer cancel()
-		c.IndentedJSON(200, searchmovies)
+
+		searchmovies := []models.MovieSearchResult{}
+		for _, match := range scored {
+			result := models.MovieSearchResult{
+				Movie:      match.Movie,
+				Score:      match.Score,
+				Highlights: map[string]string{},
+			}
+			highlight := func(field string, value *string) {
+				if value != nil {
+					if snippet := search.Highlight(*value, terms); snippet != "" {
+						result.Highlights[field] = snippet
+					}
+				}
+			}
+			highlight("name", match.Movie.Name)
+			highlight("topic", match.Movie.Topic)
+			genreNames := []string{}
+			for _, genre := range match.Movie.Genres {
+				if genre.Name != nil {
+					genreNames = append(genreNames, *genre.Name)
+				}
+			}
+			if len(genreNames) > 0 {
+				names := strings.Join(genreNames, ", ")
+				highlight("genres", &names)
+			}
+			searchmovies = append(searchmovies, result)
+		}
+		response.Success(c, http.StatusOK, searchmovies)
 	}
 }
 
-func SearchMovieByGenre() gin.HandlerFunc {
+// Filter movies by genre. Several genre_ids may be given; match=any (the
+// default) returns movies in at least one of them, match=all only movies in
+// every one. Results are paged like every other list.
+func SearchMovieByGenre(movies repository.MovieRepository, genres repository.GenreRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var searchbygenre []models.Movie
-		queryParam := c.Query("genre_id")
-		if queryParam == "" {
-			log.Println("query is empty")
-			c.Header("Content-Type", "application/json")
-			c.JSON(http.StatusNotFound, gin.H{"Error": "Invalid Search Index"})
-			c.Abort()
+		genreIds := genreIdsQuery(c)
+		if len(genreIds) == 0 {
+			response.Fail(c, response.BadRequest("at least one genre_ids value is required"))
+			return
+		}
+		match := c.DefaultQuery("match", "any")
+		if match != "any" && match != "all" {
+			response.Fail(c, response.BadRequest("match must be any or all"))
 			return
 		}
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
-		searchdb, err := movieCollection.Find(ctx, bson.M{"genre_id": bson.M{"$regex": queryParam}})
+		filter, req, err := listRequest(c, repository.MovieFields)
 		if err != nil {
-			c.IndentedJSON(404, "something went wrong in fetching the dbquery")
+			response.Fail(c, err)
 			return
 		}
-		err = searchdb.All(ctx, &searchbygenre)
-		if err != nil {
-			log.Println(err)
-			c.IndentedJSON(400, "invalid")
-			return
+		searchbygenre, err := movies.ListByGenres(ctx, genreIds, match == "all", filter, req)
+		if err == nil {
+			err = embedAllGenres(ctx, genres, searchbygenre.Items)
 		}
-		defer searchdb.Close(ctx)
-		if err := searchdb.Err(); err != nil {
-			log.Println(err)
-			c.IndentedJSON(400, "invalid request")
+		if err != nil {
+			response.Fail(c, err)
 			return
 		}
-		defer cancel()
-		c.IndentedJSON(200, searchbygenre)
+		response.Success(c, http.StatusOK, pageBody("movie_items", searchbygenre))
 	}
 }
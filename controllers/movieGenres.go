@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+
+	"github.com/genesdemon/golang-jwt-project/models"
+	"github.com/genesdemon/golang-jwt-project/repository"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// uniqueGenreIds drops empty and repeated genre IDs, keeping the first
+// occurrence of each.
+func uniqueGenreIds(genreIds []string) []string {
+	seen := map[string]bool{}
+	unique := []string{}
+	for _, id := range genreIds {
+		id = strings.TrimSpace(id)
+		if id != "" && !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// missingGenre returns the first of genreIds that does not refer to a stored
+// genre, or "" when they all do.
+func missingGenre(ctx context.Context, genres repository.GenreRepository, genreIds []string) (string, error) {
+	objIds := []primitive.ObjectID{}
+	for _, id := range genreIds {
+		objId, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			return id, nil
+		}
+		objIds = append(objIds, objId)
+	}
+	found, err := genres.FindByIDs(ctx, objIds)
+	if err != nil {
+		return "", err
+	}
+	stored := map[string]bool{}
+	for _, genre := range found {
+		stored[genre.Id.Hex()] = true
+	}
+	for _, id := range genreIds {
+		if !stored[id] {
+			return id, nil
+		}
+	}
+	return "", nil
+}
+
+// embedGenres fills in the Genres of each movie from its Genre_ids. IDs of
+// genres that no longer exist are embedded without a name.
+func embedGenres(ctx context.Context, genres repository.GenreRepository, movies ...*models.Movie) error {
+	objIds := []primitive.ObjectID{}
+	for _, movie := range movies {
+		for _, id := range movie.Genre_ids {
+			if objId, err := primitive.ObjectIDFromHex(id); err == nil {
+				objIds = append(objIds, objId)
+			}
+		}
+	}
+	found, err := genres.FindByIDs(ctx, objIds)
+	if err != nil {
+		return err
+	}
+	names := map[string]*string{}
+	for _, genre := range found {
+		names[genre.Id.Hex()] = genre.Name
+	}
+	for _, movie := range movies {
+		movie.Genres = []models.GenreRef{}
+		for _, id := range movie.Genre_ids {
+			movie.Genres = append(movie.Genres, models.GenreRef{Id: id, Name: names[id]})
+		}
+	}
+	return nil
+}
+
+func embedAllGenres(ctx context.Context, genres repository.GenreRepository, movies []models.Movie) error {
+	refs := make([]*models.Movie, len(movies))
+	for i := range movies {
+		refs[i] = &movies[i]
+	}
+	return embedGenres(ctx, genres, refs...)
+}
+
+// genreIdsQuery reads genre IDs from repeated or comma separated genre_ids
+// parameters, plus the older single genre_id parameter.
+func genreIdsQuery(c *gin.Context) []string {
+	var genreIds []string
+	for _, value := range append(c.QueryArray("genre_ids"), c.QueryArray("genre_id")...) {
+		genreIds = append(genreIds, strings.Split(value, ",")...)
+	}
+	return uniqueGenreIds(genreIds)
+}
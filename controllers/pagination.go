@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/genesdemon/golang-jwt-project/pagination"
+	"github.com/genesdemon/golang-jwt-project/query"
+	"github.com/genesdemon/golang-jwt-project/response"
+	"github.com/gin-gonic/gin"
+)
+
+// listRequest reads the sort and filter parameters allowed by fields and the
+// limit, cursor and total parameters shared by the list endpoints.
+// recordPerPage is still accepted in place of limit.
+func listRequest(c *gin.Context, fields query.Schema) (query.Filter, pagination.Request, error) {
+	filter, sort, err := query.Parse(c.Request.URL.Query(), fields)
+	if problems, ok := err.(query.ValidationError); ok {
+		return nil, pagination.Request{}, queryError(problems)
+	}
+	if err != nil {
+		return nil, pagination.Request{}, err
+	}
+
+	req := pagination.Request{Limit: pagination.DefaultLimit, Sort: sort}
+	limit := c.Query("limit")
+	if limit == "" {
+		limit = c.Query("recordPerPage")
+	}
+	if n, err := strconv.Atoi(limit); err == nil && n > 0 {
+		req.Limit = n
+	}
+	if req.Limit > pagination.MaxLimit {
+		req.Limit = pagination.MaxLimit
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		decoded, err := pagination.Decode(cursor, sort)
+		if err != nil {
+			return nil, req, response.BadRequest(err.Error())
+		}
+		req.Cursor = decoded
+	}
+	req.WithTotal, _ = strconv.ParseBool(c.Query("total"))
+	return filter, req, nil
+}
+
+// pageBody is the response of a list endpoint: the items under itemsKey, the
+// cursors of the neighbouring pages and, when asked for, the total count.
+func pageBody[T any](itemsKey string, page *pagination.Page[T]) gin.H {
+	body := gin.H{
+		itemsKey:      page.Items,
+		"next_cursor": page.Next,
+		"prev_cursor": page.Prev,
+	}
+	if page.Total != nil {
+		body["total_count"] = *page.Total
+	}
+	return body
+}
+
+// queryError reports each problem with the sort and filter parameters against
+// the parameter it was found in.
+func queryError(problems query.ValidationError) *response.Error {
+	details := make([]response.FieldError, 0, len(problems))
+	for _, problem := range problems {
+		param, message, _ := strings.Cut(problem, ": ")
+		details = append(details, response.FieldError{Field: param, Rule: "query", Message: message})
+	}
+	return response.ValidationFailed("invalid list query", details...)
+}
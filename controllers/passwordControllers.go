@@ -0,0 +1,167 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/genesdemon/golang-jwt-project/config"
+	helper "github.com/genesdemon/golang-jwt-project/helpers"
+	"github.com/genesdemon/golang-jwt-project/mailer"
+	"github.com/genesdemon/golang-jwt-project/models"
+	"github.com/genesdemon/golang-jwt-project/repository"
+	"github.com/genesdemon/golang-jwt-project/response"
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	passwordResetTTL = time.Hour
+	passwordResetURL = ""
+	//every reset request counts, per client address and per email, with the
+	//backoffs of sign ins, so nobody can flood an inbox
+	resetRequestsByIP    = helper.NewThrottle(helper.IPBackoff(config.Default().Login))
+	resetRequestsByEmail = helper.NewThrottle(helper.AccountBackoff(config.Default().Login))
+)
+
+const (
+	//reset requests waiting for a worker; more are refused
+	resetQueueSize = 100
+	resetWorkers   = 4
+)
+
+// Send a password reset token to the email of an account. The answer is the
+// same whether or not the account exists and is given before the account is
+// looked up, so neither it nor how long it takes tells which emails are
+// registered. A few workers send the tokens from a bounded queue.
+func ForgotPassword(users repository.UserRepository, mail mailer.Mailer) gin.HandlerFunc {
+	queue := make(chan string, resetQueueSize)
+	for i := 0; i < resetWorkers; i++ {
+		go func() {
+			for email := range queue {
+				sendPasswordReset(users, mail, email)
+			}
+		}()
+	}
+
+	return func(c *gin.Context) {
+		var body struct {
+			Email *string `json:"email" validate:"required,email"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			response.Fail(c, response.Invalid(err))
+			return
+		}
+		if validationErr := validate.Struct(&body); validationErr != nil {
+			response.Fail(c, response.Invalid(validationErr))
+			return
+		}
+
+		now := time.Now()
+		email := strings.ToLower(*body.Email)
+		wait := resetRequestsByIP.Wait(c.ClientIP(), now)
+		if emailWait := resetRequestsByEmail.Wait(email, now); emailWait > wait {
+			wait = emailWait
+		}
+		if wait > 0 {
+			retryLater(c, wait, "too many password reset requests, try again later")
+			return
+		}
+		resetRequestsByIP.Fail(c.ClientIP(), now)
+		resetRequestsByEmail.Fail(email, now)
+
+		select {
+		case queue <- *body.Email:
+		default:
+			retryLater(c, time.Minute, "too many password reset requests, try again later")
+			return
+		}
+		response.Success(c, http.StatusAccepted, "If an account uses this email, a password reset link has been sent to it")
+	}
+}
+
+// sendPasswordReset stores a new reset token for the account using email, if
+// there is one, and mails it. A worker of ForgotPassword runs it after the
+// request was answered, so failures can only be logged.
+func sendPasswordReset(users repository.UserRepository, mail mailer.Mailer, email string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	user, err := users.FindByEmail(ctx, email)
+	if err == repository.ErrNotFound {
+		return
+	}
+	if err != nil {
+		log.Printf("looking up the account for a password reset: %v", err)
+		return
+	}
+
+	token, hash, err := helper.NewOneTimeToken()
+	if err != nil {
+		log.Printf("creating a password reset token for %s: %v", user.User_id, err)
+		return
+	}
+	expiresAt := time.Now().Add(passwordResetTTL)
+	if err := users.SetResetToken(ctx, user.User_id, hash, expiresAt); err != nil {
+		log.Printf("storing the password reset token of %s: %v", user.User_id, err)
+		return
+	}
+
+	msg := resetMessage(user, token, expiresAt)
+	if err := mail.Send(ctx, msg); err != nil {
+		log.Printf("sending password reset to %s: %v", msg.To, err)
+	}
+}
+
+func resetMessage(user *models.User, token string, expiresAt time.Time) mailer.Message {
+	instructions := "Use this token to choose a new password:\n\n" + token
+	if passwordResetURL != "" {
+		instructions = "Follow this link to choose a new password:\n\n" + passwordResetURL + "?token=" + url.QueryEscape(token)
+	}
+	return mailer.Message{
+		To:      *user.Email,
+		Subject: "Reset your Shive password",
+		Body: fmt.Sprintf("Hello %s,\n\nSomeone asked to reset the password of your Shive account. %s\n\nIt can be used once, until %s. If you did not ask for this, you can ignore this email.\n",
+			*user.Name, instructions, expiresAt.UTC().Format(time.RFC1123)),
+	}
+}
+
+// Choose a new password with a token from ForgotPassword. Every session of
+// the account is signed out.
+func ResetPassword(users repository.UserRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		var body struct {
+			Token    *string `json:"token" validate:"required"`
+			Password *string `json:"Password" validate:"required,min=8"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			response.Fail(c, response.Invalid(err))
+			return
+		}
+		if validationErr := validate.Struct(&body); validationErr != nil {
+			response.Fail(c, response.Invalid(validationErr))
+			return
+		}
+
+		user, err := users.ConsumeResetToken(ctx, helper.HashOneTimeToken(*body.Token), time.Now())
+		if err == repository.ErrNotFound {
+			response.Fail(c, response.BadRequest("the reset token is invalid, expired or already used"))
+			return
+		}
+		if err != nil {
+			response.Fail(c, err)
+			return
+		}
+		if err := users.SetPassword(ctx, user.User_id, HashPassword(*body.Password)); err != nil {
+			response.Fail(c, err)
+			return
+		}
+		response.Success(c, http.StatusOK, "Your password has been reset, please sign in again")
+	}
+}
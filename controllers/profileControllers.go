@@ -0,0 +1,147 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/genesdemon/golang-jwt-project/mailer"
+	"github.com/genesdemon/golang-jwt-project/models"
+	"github.com/genesdemon/golang-jwt-project/repository"
+	"github.com/genesdemon/golang-jwt-project/response"
+	"github.com/gin-gonic/gin"
+)
+
+// updateProfile applies the profile changes in the request body to user,
+// refusing an email or username another account already uses like Signup
+// does, and answers with the updated user. A new email is sent a
+// verification link.
+func updateProfile(ctx context.Context, c *gin.Context, users repository.UserRepository, mail mailer.Mailer, user *models.User) {
+	var update models.UserUpdate
+	if err := c.BindJSON(&update); err != nil {
+		response.Fail(c, response.Invalid(err))
+		return
+	}
+	if validationErr := validate.Struct(&update); validationErr != nil {
+		response.Fail(c, response.Invalid(validationErr))
+		return
+	}
+
+	//only values that change are checked and written, and a change of case
+	//is still the same email or username, so it is neither
+	if update.Email != nil && strings.EqualFold(*update.Email, *user.Email) {
+		update.Email = nil
+	}
+	if update.Username != nil && strings.EqualFold(*update.Username, *user.Username) {
+		update.Username = nil
+	}
+	if update.Email != nil {
+		emailTaken, err := users.EmailExists(ctx, *update.Email)
+		if err != nil {
+			response.Fail(c, err)
+			return
+		}
+		if emailTaken {
+			response.Fail(c, response.Conflict("this email already exists"))
+			return
+		}
+	}
+	if update.Username != nil {
+		usernameTaken, err := users.UsernameExists(ctx, *update.Username)
+		if err != nil {
+			response.Fail(c, err)
+			return
+		}
+		if usernameTaken {
+			response.Fail(c, response.Conflict("this username already exists"))
+			return
+		}
+	}
+
+	updated, err := users.UpdateProfile(ctx, user.User_id, update)
+	if err != nil {
+		response.Fail(c, lookupError(err, "User with specified ID not found!"))
+		return
+	}
+	if update.Email != nil {
+		if err := sendVerification(ctx, users, mail, updated); err != nil {
+			log.Printf("starting email verification for %s: %v", *updated.Email, err)
+		}
+	}
+	response.Success(c, http.StatusOK, updated)
+}
+
+// Update the name, username or email of the signed in user
+func UpdateMe(users repository.UserRepository, mail mailer.Mailer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		user, err := signedInUser(ctx, c, users)
+		if err != nil {
+			response.Fail(c, err)
+			return
+		}
+		updateProfile(ctx, c, users, mail, user)
+	}
+}
+
+// For Admin to update the name, username or email of any user
+func UpdateUser(users repository.UserRepository, mail mailer.Mailer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		user, err := users.FindByUserID(ctx, c.Param("user_id"))
+		if err != nil {
+			response.Fail(c, lookupError(err, "User with specified ID not found!"))
+			return
+		}
+		updateProfile(ctx, c, users, mail, user)
+	}
+}
+
+// Change the password of the signed in user. Every other session is signed
+// out and this one gets a new token pair.
+func ChangePassword(users repository.UserRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		var body struct {
+			Current_password *string `json:"current_password" validate:"required"`
+			Password         *string `json:"Password" validate:"required,min=8"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			response.Fail(c, response.Invalid(err))
+			return
+		}
+		if validationErr := validate.Struct(&body); validationErr != nil {
+			response.Fail(c, response.Invalid(validationErr))
+			return
+		}
+
+		user, err := signedInUser(ctx, c, users)
+		if err != nil {
+			response.Fail(c, err)
+			return
+		}
+		if passwordIsValid, _ := VerifyPassword(*body.Current_password, *user.Password); !passwordIsValid {
+			response.Fail(c, response.Forbidden("the current password is incorrect"))
+			return
+		}
+		if err := users.SetPassword(ctx, user.User_id, HashPassword(*body.Password)); err != nil {
+			response.Fail(c, err)
+			return
+		}
+		//the new token pair must carry the token version SetPassword bumped
+		user, err = users.FindByUserID(ctx, user.User_id)
+		if err != nil {
+			response.Fail(c, err)
+			return
+		}
+		completeLogin(ctx, c, users, user)
+	}
+}
@@ -2,185 +2,272 @@ package controllers
 
 import (
 	"context"
-	"log"
 	"net/http"
 	"time"
 
-	"github.com/genesdemon/golang-jwt-project/database"
 	helper "github.com/genesdemon/golang-jwt-project/helpers"
 	"github.com/genesdemon/golang-jwt-project/models"
+	"github.com/genesdemon/golang-jwt-project/rbac"
+	"github.com/genesdemon/golang-jwt-project/repository"
+	"github.com/genesdemon/golang-jwt-project/response"
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
-var reviewCollection *mongo.Collection = database.OpenCollection(database.Client, "review")
-
-//Add  new review
-func AddAReview() gin.HandlerFunc {
+// Add  new review
+func AddAReview(tx repository.Transactor, reviews repository.ReviewRepository, movies repository.MovieRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if err := helper.CheckUserType(c, "USER"); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		var review models.Reviews
 		defer cancel()
 
 		//validate the request body
 		if err := c.BindJSON(&review); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"Status":  http.StatusBadRequest,
-				"Message": "error",
-				"Data":    map[string]interface{}{"data": err.Error()}})
+			response.Fail(c, response.Invalid(err))
+			return
+		}
+		//the reviewer is always the signed in user
+		uid := c.GetString("uid")
+		if uid == "" {
+			response.Fail(c, response.Forbidden("API keys cannot post reviews, sign in as a user"))
+			return
+		}
+		if review.Reviewer_id != nil && *review.Reviewer_id != uid {
+			response.Fail(c, response.Forbidden("reviewer_id does not match the signed in user"))
+			return
+		}
+		review.Reviewer_id = &uid
+		if reviewsRequireVerifiedEmail && !c.GetBool("email_verified") {
+			response.Fail(c, response.Forbidden("verify your email before posting reviews"))
 			return
 		}
+
 		//use the validator library to validate required fields
 		if validationErr := validate.Struct(&review); validationErr != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"Status":  http.StatusBadRequest,
-				"Message": "error",
-				"Data":    map[string]interface{}{"data": validationErr.Error()}})
+			response.Fail(c, response.Invalid(validationErr))
+			return
+		}
+
+		//make sure the movie being reviewed exists
+		movieId, err := primitive.ObjectIDFromHex(*review.Movie_id)
+		if err != nil {
+			response.Fail(c, response.BadRequest("movie_id is not a valid id"))
+			return
+		}
+		if _, err := movies.FindByID(ctx, movieId); err == repository.ErrNotFound {
+			response.Fail(c, response.NotFound("Movie with specified ID not found!"))
+			return
+		} else if err != nil {
+			response.Fail(c, err)
 			return
 		}
 
+		now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		newReview := models.Reviews{
 			Id:          primitive.NewObjectID(),
 			Movie_id:    review.Movie_id,
 			Reviewer_id: review.Reviewer_id,
 			Review:      review.Review,
+			Rating:      review.Rating,
+			Created_at:  now,
+			Updated_at:  now,
 		}
 
-		result, err := reviewCollection.InsertOne(ctx, newReview)
-
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"Status":  http.StatusBadRequest,
-				"Message": "error",
-				"Data":    map[string]interface{}{"data": err.Error()}})
+		//the review and its rating are stored together, or a failed rating
+		//would leave a review that counts nowhere and refuses a retry
+		err = tx.WithTransaction(ctx, func(ctx context.Context) error {
+			if err := reviews.Create(ctx, &newReview); err != nil {
+				return err
+			}
+			return applyReviewRating(ctx, movies, nil, &newReview)
+		})
+		if err == repository.ErrDuplicate {
+			response.Fail(c, response.Conflict("you have already reviewed this movie, edit your existing review instead"))
 			return
 		}
-
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"Status":  http.StatusInternalServerError,
-				"Message": "error",
-				"Data":    map[string]interface{}{"data": err.Error()}})
+			response.Fail(c, err)
 			return
 		}
 
-		c.JSON(http.StatusCreated, gin.H{
-			"Status":  http.StatusCreated,
-			"Message": "success",
-			"Data":    map[string]interface{}{"data": result}})
+		response.Success(c, http.StatusCreated, gin.H{"InsertedID": newReview.Id})
 	}
 }
 
-//View the reviews of a specific movie
-func ViewAMovieReviews() gin.HandlerFunc {
+// View the reviews of a specific movie
+func ViewAMovieReviews(reviews repository.ReviewRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var searchreviews []models.Reviews
 		queryParam := c.Query("movie_id")
 		if queryParam == "" {
-			log.Println("No movie id passed")
-			c.Header("Content-Type", "application/json")
-			c.JSON(http.StatusNotFound, gin.H{"Error": "Invalid Search Index"})
-			c.Abort()
+			response.Fail(c, response.BadRequest("the movie_id query parameter is required"))
 			return
 		}
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
-		searchquerydb, err := reviewCollection.Find(ctx, bson.M{"movie_id": bson.M{"$regex": queryParam}})
+		filter, req, err := listRequest(c, repository.ReviewFields)
+		if err != nil {
+			response.Fail(c, err)
+			return
+		}
+		searchreviews, err := reviews.FindByMovie(ctx, queryParam, filter, req)
 		if err != nil {
-			c.IndentedJSON(404, "something went wrong in fetching the dbquery")
+			response.Fail(c, err)
 			return
 		}
-		err = searchquerydb.All(ctx, &searchreviews)
+		response.Success(c, http.StatusOK, pageBody("review_items", searchreviews))
+	}
+}
+
+// Edit your own review
+func EditAReview(tx repository.Transactor, reviews repository.ReviewRepository, movies repository.MovieRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		objId, err := pathID(c, "_id")
 		if err != nil {
-			log.Println(err)
-			c.IndentedJSON(400, "invalid")
+			response.Fail(c, err)
 			return
 		}
-		defer searchquerydb.Close(ctx)
-		if err := searchquerydb.Err(); err != nil {
-			log.Println(err)
-			c.IndentedJSON(400, "invalid request")
+
+		var edit models.ReviewEdit
+		if err := c.BindJSON(&edit); err != nil {
+			response.Fail(c, response.Invalid(err))
 			return
 		}
-		defer cancel()
-		c.IndentedJSON(200, searchreviews)
+		if validationErr := validate.Struct(&edit); validationErr != nil {
+			response.Fail(c, response.Invalid(validationErr))
+			return
+		}
+
+		review, err := reviews.FindByID(ctx, objId)
+		if err == repository.ErrNotFound {
+			response.Fail(c, response.NotFound("Review with specified ID not found!"))
+			return
+		}
+		if err != nil {
+			response.Fail(c, err)
+			return
+		}
+
+		//only the author may change a review
+		if review.Reviewer_id == nil || c.GetString("uid") == "" || c.GetString("uid") != *review.Reviewer_id {
+			response.Fail(c, response.Forbidden("you can only edit your own reviews"))
+			return
+		}
+
+		now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		previous := models.ReviewRevision{
+			Review:    review.Review,
+			Rating:    review.Rating,
+			Edited_at: now,
+		}
+		var updatedReview *models.Reviews
+		err = tx.WithTransaction(ctx, func(ctx context.Context) error {
+			var err error
+			if updatedReview, err = reviews.Update(ctx, objId, &edit, previous); err != nil {
+				return err
+			}
+			return applyReviewRating(ctx, movies, review, updatedReview)
+		})
+		if err != nil {
+			response.Fail(c, err)
+			return
+		}
+
+		response.Success(c, http.StatusOK, updatedReview)
 	}
 }
 
-//Delete a review
-func DeleteAReview() gin.HandlerFunc {
+// Delete a review
+func DeleteAReview(tx repository.Transactor, reviews repository.ReviewRepository, movies repository.MovieRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		reviewId := c.Param("_id")
 		defer cancel()
-		objId, _ := primitive.ObjectIDFromHex(reviewId)
-
-		result, err := reviewCollection.DeleteOne(ctx, bson.M{"_id": objId})
+		objId, err := pathID(c, "_id")
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"Status":  http.StatusInternalServerError,
-				"Message": "error",
-				"Data":    map[string]interface{}{"data": err.Error()}})
+			response.Fail(c, err)
 			return
 		}
 
-		if result.DeletedCount < 1 {
-			c.JSON(http.StatusNotFound,
-				gin.H{
-					" Status":  http.StatusNotFound,
-					" Message": "error",
-					" Data":    map[string]interface{}{"data": "Review with specified ID not found!"}},
-			)
+		review, err := reviews.FindByID(ctx, objId)
+		if err == nil {
+			if err := helper.MatchOwnerOr(c, stringValue(review.Reviewer_id), rbac.ReviewModerate); err != nil {
+				response.Fail(c, response.Forbidden(err.Error()))
+				return
+			}
+			err = tx.WithTransaction(ctx, func(ctx context.Context) error {
+				if err := reviews.Delete(ctx, objId); err != nil {
+					return err
+				}
+				return applyReviewRating(ctx, movies, review, nil)
+			})
+		}
+		if err != nil {
+			response.Fail(c, lookupError(err, "Review with specified ID not found!"))
 			return
 		}
 
-		c.JSON(http.StatusOK,
-			gin.H{
-				"Status":  http.StatusOK,
-				"Message": "success",
-				"Data":    map[string]interface{}{"data": "Your review was successfully deleted!"}},
-		)
+		response.Success(c, http.StatusOK, "Your review was successfully deleted!")
 	}
 }
 
 // Allow a user view all their Reviews
-func AllUserReviews() gin.HandlerFunc {
+func AllUserReviews(reviews repository.ReviewRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var searchreviews []models.Reviews
 		queryParam := c.Query("reviewer_id")
 		if queryParam == "" {
-			log.Println("No reviewer id passed")
-			c.Header("Content-Type", "application/json")
-			c.JSON(http.StatusNotFound, gin.H{"Error": "Invalid Search Index"})
-			c.Abort()
+			response.Fail(c, response.BadRequest("the reviewer_id query parameter is required"))
 			return
 		}
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
-		searchquerydb, err := reviewCollection.Find(ctx, bson.M{"reviewer_id": bson.M{"$regex": queryParam}})
+		filter, req, err := listRequest(c, repository.ReviewFields)
 		if err != nil {
-			c.IndentedJSON(404, "something went wrong in fetching the dbquery")
+			response.Fail(c, err)
 			return
 		}
-		err = searchquerydb.All(ctx, &searchreviews)
+		searchreviews, err := reviews.FindByReviewer(ctx, queryParam, filter, req)
 		if err != nil {
-			log.Println(err)
-			c.IndentedJSON(400, "invalid")
-			return
-		}
-		defer searchquerydb.Close(ctx)
-		if err := searchquerydb.Err(); err != nil {
-			log.Println(err)
-			c.IndentedJSON(400, "invalid request")
+			response.Fail(c, err)
 			return
 		}
-		defer cancel()
-		c.IndentedJSON(200, searchreviews)
+		response.Success(c, http.StatusOK, pageBody("review_items", searchreviews))
+	}
+}
+
+// applyReviewRating replaces the rating of review before with the rating of
+// review after in their movie's rating summary, in one update. A new review
+// has no before and a deleted one no after. Reviews written before ratings
+// existed and reviews of movies that are gone are skipped.
+func applyReviewRating(ctx context.Context, movies repository.MovieRepository, before *models.Reviews, after *models.Reviews) error {
+	review := after
+	if review == nil {
+		review = before
+	}
+	var removed, added *int
+	if before != nil {
+		removed = before.Rating
+	}
+	if after != nil {
+		added = after.Rating
+	}
+	if removed == nil && added == nil || review.Movie_id == nil {
+		return nil
+	}
+	movieId, err := primitive.ObjectIDFromHex(*review.Movie_id)
+	if err != nil {
+		return nil
+	}
+	err = movies.ApplyRating(ctx, movieId, removed, added)
+	if err == repository.ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+func stringValue(value *string) string {
+	if value == nil {
+		return ""
 	}
+	return *value
 }
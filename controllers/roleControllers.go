@@ -0,0 +1,212 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/genesdemon/golang-jwt-project/models"
+	"github.com/genesdemon/golang-jwt-project/rbac"
+	"github.com/genesdemon/golang-jwt-project/repository"
+	"github.com/genesdemon/golang-jwt-project/response"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// unknownPermissions reports every permission that rbac does not know about.
+func unknownPermissions(permissions []string) error {
+	var details []response.FieldError
+	for i, permission := range permissions {
+		if !rbac.Known(permission) {
+			details = append(details, response.FieldError{
+				Field:   fmt.Sprintf("permissions[%d]", i),
+				Rule:    "permission",
+				Message: fmt.Sprintf("%q is not a known permission", permission),
+			})
+		}
+	}
+	if details != nil {
+		return response.ValidationFailed("the request contains unknown permissions", details...).With("known", rbac.All)
+	}
+	return nil
+}
+
+// For Admin to define a new role
+func CreateRole(roles repository.RoleRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		var role models.Role
+		if err := c.BindJSON(&role); err != nil {
+			response.Fail(c, response.Invalid(err))
+			return
+		}
+		if validationErr := validate.Struct(&role); validationErr != nil {
+			response.Fail(c, response.Invalid(validationErr))
+			return
+		}
+		if err := unknownPermissions(role.Permissions); err != nil {
+			response.Fail(c, err)
+			return
+		}
+
+		//role names are stored upper case like the built-in ones
+		name := strings.ToUpper(*role.Name)
+		now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		newRole := models.Role{
+			Id:          primitive.NewObjectID(),
+			Name:        &name,
+			Permissions: role.Permissions,
+			Created_at:  now,
+			Updated_at:  now,
+		}
+		err := roles.Create(ctx, &newRole)
+		if err == repository.ErrDuplicate {
+			response.Fail(c, response.Conflict("this role name already exists"))
+			return
+		}
+		if err != nil {
+			response.Fail(c, err)
+			return
+		}
+		response.Success(c, http.StatusCreated, newRole)
+	}
+}
+
+func GetRoles(roles repository.RoleRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		filter, req, err := listRequest(c, repository.RoleFields)
+		if err != nil {
+			response.Fail(c, err)
+			return
+		}
+		page, err := roles.List(ctx, filter, req)
+		if err != nil {
+			response.Fail(c, err)
+			return
+		}
+		response.Success(c, http.StatusOK, pageBody("role_items", page))
+	}
+}
+
+// For Admin to replace the permissions a role grants
+func UpdateRolePermissions(roles repository.RoleRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		var body struct {
+			Permissions []string `json:"permissions" validate:"required,dive,required"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			response.Fail(c, response.Invalid(err))
+			return
+		}
+		if validationErr := validate.Struct(&body); validationErr != nil {
+			response.Fail(c, response.Invalid(validationErr))
+			return
+		}
+		if err := unknownPermissions(body.Permissions); err != nil {
+			response.Fail(c, err)
+			return
+		}
+
+		role, err := roles.SetPermissions(ctx, strings.ToUpper(c.Param("role_name")), body.Permissions)
+		if err != nil {
+			response.Fail(c, lookupError(err, "role not found"))
+			return
+		}
+		response.Success(c, http.StatusOK, role)
+	}
+}
+
+// For Admin to give a user a role
+func AssignRole(users repository.UserRepository, roles repository.RoleRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		var body struct {
+			Role *string `json:"role" validate:"required"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			response.Fail(c, response.Invalid(err))
+			return
+		}
+		if validationErr := validate.Struct(&body); validationErr != nil {
+			response.Fail(c, response.Invalid(validationErr))
+			return
+		}
+
+		role, err := roles.FindByName(ctx, strings.ToUpper(*body.Role))
+		if err != nil {
+			response.Fail(c, lookupError(err, "role not found"))
+			return
+		}
+		user, err := users.AddRole(ctx, c.Param("user_id"), *role.Name)
+		if err != nil {
+			response.Fail(c, lookupError(err, "User with specified ID not found!"))
+			return
+		}
+		response.Success(c, http.StatusOK, user)
+	}
+}
+
+// For Admin to take a role away from a user
+func UnassignRole(users repository.UserRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		user, err := users.RemoveRole(ctx, c.Param("user_id"), strings.ToUpper(c.Param("role_name")))
+		if err != nil {
+			response.Fail(c, lookupError(err, "User with specified ID not found!"))
+			return
+		}
+		response.Success(c, http.StatusOK, user)
+	}
+}
+
+// For Admin to promote a user to ADMIN or demote one back to USER
+func SetUserType(users repository.UserRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		var body struct {
+			User_type *string `json:"user_type" validate:"required,eq=ADMIN|eq=USER"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			response.Fail(c, response.Invalid(err))
+			return
+		}
+		if validationErr := validate.Struct(&body); validationErr != nil {
+			response.Fail(c, response.Invalid(validationErr))
+			return
+		}
+		userId := c.Param("user_id")
+		//stops an admin from locking everyone out by demoting themselves
+		if userId == c.GetString("uid") {
+			response.Fail(c, response.Forbidden("you cannot change your own user type"))
+			return
+		}
+
+		//demoting also takes away an ADMIN role assigned on its own
+		var revoke []string
+		if *body.User_type != rbac.Admin {
+			revoke = []string{rbac.Admin}
+		}
+		user, err := users.SetUserType(ctx, userId, *body.User_type, revoke)
+		if err != nil {
+			response.Fail(c, lookupError(err, "User with specified ID not found!"))
+			return
+		}
+		response.Success(c, http.StatusOK, user)
+	}
+}
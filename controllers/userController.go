@@ -5,25 +5,45 @@ import (
 	"fmt"
 	"log"
 	"net/http"
-	"strconv"
 	"time"
 
-	"github.com/genesdemon/golang-jwt-project/database"
+	"github.com/genesdemon/golang-jwt-project/config"
 	helper "github.com/genesdemon/golang-jwt-project/helpers"
+	"github.com/genesdemon/golang-jwt-project/mailer"
 	"github.com/genesdemon/golang-jwt-project/models"
+	"github.com/genesdemon/golang-jwt-project/rbac"
+	"github.com/genesdemon/golang-jwt-project/repository"
+	"github.com/genesdemon/golang-jwt-project/response"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 	"golang.org/x/crypto/bcrypt"
 )
 
-var userCollection *mongo.Collection = database.OpenCollection(database.Client, "user")
 var validate = validator.New()
 
+var bcryptCost = bcrypt.DefaultCost
+
+var accessTTL = 24 * time.Hour
+
+// Configure applies the settings the controllers read at request time.
+func Configure(cfg *config.Config) {
+	bcryptCost = cfg.BcryptCost
+	genreDeletePolicy = cfg.DeletePolicy.Genre
+	movieDeletePolicy = cfg.DeletePolicy.Movie
+	passwordResetTTL = cfg.PasswordResetTTL
+	passwordResetURL = cfg.Mail.ResetURL
+	emailVerificationTTL = cfg.EmailVerificationTTL
+	emailVerifyURL = cfg.Mail.VerifyURL
+	reviewsRequireVerifiedEmail = cfg.ReviewsRequireVerifiedEmail
+	configureLockout(cfg.Login)
+	totpIssuer = cfg.TOTPIssuer
+	challengeTTL = cfg.JWT.ChallengeTTL
+	accessTTL = cfg.JWT.AccessTTL
+}
+
 func HashPassword(password string) string {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
 	if err != nil {
 		log.Panic(err)
 	}
@@ -42,7 +62,62 @@ func VerifyPassword(userPassword string, providedPassword string) (bool, string)
 	return check, msg
 }
 
-func Signup() gin.HandlerFunc {
+// createUser stores a new account of the given type for the validated sign up
+// details in user, refusing emails and usernames that are already taken.
+func createUser(ctx context.Context, users repository.UserRepository, user *models.User, userType string) (*models.User, error) {
+	//Check to see if email or username exists
+	emailTaken, err := users.EmailExists(ctx, *user.Email)
+	if err != nil {
+		return nil, err
+	}
+	if emailTaken {
+		return nil, response.Conflict("this email already exists")
+	}
+	usernameTaken, err := users.UsernameExists(ctx, *user.Username)
+	if err != nil {
+		return nil, err
+	}
+	if usernameTaken {
+		return nil, response.Conflict("this username already exists")
+	}
+
+	user.User_type = &userType
+	password := HashPassword(*user.Password)
+	user.Password = &password
+	user.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	user.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	user.ID = primitive.NewObjectID()
+	user.User_id = user.ID.Hex()
+	family := helper.NewTokenFamily()
+	token, refreshToken, err := helper.GenerateAllTokens(*user.Email, *user.Name, *user.Username, *user.User_type, user.User_id, family, user.Token_version)
+	if err != nil {
+		return nil, err
+	}
+	user.Token = &token
+	user.Sessions = []models.Session{{Family: family, Refresh_token: refreshToken, Created_at: user.Created_at}}
+
+	newUser := models.User{
+		ID:             user.ID,
+		Name:           user.Name,
+		Username:       user.Username,
+		Email:          user.Email,
+		Email_verified: user.Email_verified,
+		User_id:        user.ID.Hex(),
+		Password:       user.Password,
+		Created_at:     user.Created_at,
+		Updated_at:     user.Updated_at,
+		Token:          user.Token,
+		User_type:      user.User_type,
+		Sessions:       user.Sessions,
+	}
+
+	if err := users.Create(ctx, &newUser); err != nil {
+		return nil, err
+	}
+	return &newUser, nil
+}
+
+func Signup(users repository.UserRepository, mail mailer.Mailer) gin.HandlerFunc {
 
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -51,235 +126,260 @@ func Signup() gin.HandlerFunc {
 
 		//validate the request body
 		if err := c.BindJSON(&user); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"Status":  http.StatusBadRequest,
-				"Message": "error",
-				"Data":    map[string]interface{}{"data": err.Error()}})
-			return
-		}
-
-		//Check to see if name exists
-		regexMatch := bson.M{"$regex": primitive.Regex{Pattern: *user.Email, Options: "i"}}
-		count, err := userCollection.CountDocuments(ctx, bson.M{"email": regexMatch})
-		usernameMatch := bson.M{"$regex": primitive.Regex{Pattern: *user.Username, Options: "i"}}
-		ucount, err := userCollection.CountDocuments(ctx, bson.M{"username": usernameMatch})
-		defer cancel()
-		if err != nil {
-			log.Panic(err)
-			c.JSON(http.StatusBadRequest, gin.H{"error": "error occured while checking for the Name"})
-		}
-		if count > 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "this email already exists", "count": count})
-			return
-		}
-		if ucount > 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "this username already exists", "count": count})
+			response.Fail(c, response.Invalid(err))
 			return
 		}
 
-		password := HashPassword(*user.Password)
-		user.Password = &password
-		user.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-		user.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-		user.ID = primitive.NewObjectID()
-		user.User_id = user.ID.Hex()
-		token, refreshToken, _ := helper.GenerateAllTokens(*user.Email, *user.Name, *user.Username, *user.User_type, *&user.User_id)
-		user.Token = &token
-		user.Refresh_token = &refreshToken
-
 		//use the validator library to validate required fields
 		if validationErr := validate.Struct(&user); validationErr != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"Status":  http.StatusBadRequest,
-				"Message": "error",
-				"Data":    map[string]interface{}{"data": validationErr.Error()}})
+			response.Fail(c, response.Invalid(validationErr))
 			return
 		}
 
-		//use the validator library to validate required fields
-		if validationErr := validate.Struct(&user); validationErr != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"Status":  http.StatusBadRequest,
-				"Message": "error",
-				"Data":    map[string]interface{}{"data": validationErr.Error()}})
+		//accounts always start as USER, only an admin can promote them
+		if user.User_type != nil && *user.User_type != rbac.User {
+			response.Fail(c, response.Forbidden("signup only creates USER accounts"))
 			return
 		}
 
-		newUser := models.User{
-			ID:            user.ID,
-			Name:          user.Name,
-			Username:      user.Username,
-			Email:         user.Email,
-			User_id:       user.ID.Hex(),
-			Password:      user.Password,
-			Created_at:    user.Created_at,
-			Updated_at:    user.Updated_at,
-			Token:         user.Token,
-			User_type:     user.User_type,
-			Refresh_token: user.Refresh_token,
-		}
-
-		result, err := userCollection.InsertOne(ctx, newUser)
-
+		//the email is only trusted once the link sent to it has been followed
+		user.Email_verified = false
+		newUser, err := createUser(ctx, users, &user, rbac.User)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"Status":  http.StatusBadRequest,
-				"Message": "error",
-				"Data":    map[string]interface{}{"data": err.Error()}})
+			response.Fail(c, err)
 			return
 		}
-
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"Status":  http.StatusInternalServerError,
-				"Message": "error",
-				"Data":    map[string]interface{}{"data": err.Error()}})
-			return
+		//the account exists either way, a new link can be asked for later
+		if err := sendVerification(ctx, users, mail, models.NewPrivateUser(newUser)); err != nil {
+			log.Printf("starting email verification for %s: %v", *newUser.Email, err)
 		}
 
-		c.JSON(http.StatusCreated, gin.H{
-			"Status":  http.StatusCreated,
-			"Message": "success",
-			"Data":    map[string]interface{}{"data": result}})
+		response.Success(c, http.StatusCreated, gin.H{"InsertedID": newUser.ID})
 	}
 
 }
 
-func Login() gin.HandlerFunc {
+func Login(users repository.UserRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		var user models.User
-		var foundUser models.User
 		defer cancel()
 		if err := c.BindJSON(&user); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			response.Fail(c, response.Invalid(err))
+			return
+		}
+		if user.Email == nil || user.Password == nil {
+			response.Fail(c, response.BadRequest("email and password are required"))
 			return
 		}
 
-		err := userCollection.FindOne(ctx, bson.M{"email": user.Email}).Decode(&foundUser)
-		defer cancel()
+		now := time.Now()
+		if wait := ipLogins.Wait(c.ClientIP(), now); wait > 0 {
+			tooManyLogins(c, wait)
+			return
+		}
+
+		foundUser, err := users.FindByEmail(ctx, *user.Email)
+		if err == repository.ErrNotFound {
+			failUnknownLogin(c, *user.Email, *user.Password, now)
+			return
+		}
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "email or password is incorrect"})
+			response.Fail(c, err)
+			return
+		}
+		//a locked account is refused before the password is even checked
+		if foundUser.Locked_until != nil && now.Before(*foundUser.Locked_until) {
+			tooManyLogins(c, foundUser.Locked_until.Sub(now))
 			return
 		}
 
-		passwordIsValid, msg := VerifyPassword(*user.Password, *foundUser.Password)
-		defer cancel()
+		passwordIsValid, _ := VerifyPassword(*user.Password, *foundUser.Password)
 		if passwordIsValid != true {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			failLogin(ctx, c, users, foundUser.User_id, now, loginFailed)
 			return
 		}
-
-		if foundUser.Email == nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "user not found"})
+		//failures are only forgotten once the code is right too, or the
+		//password alone would reset the lockout on code guesses
+		if foundUser.Mfa_enabled {
+			challengeMFA(c, foundUser)
+			return
 		}
-		token, refreshToken, _ := helper.GenerateAllTokens(*foundUser.Email, *foundUser.Name, *foundUser.Username, *foundUser.User_type, foundUser.User_id)
-		helper.UpdateAllTokens(token, refreshToken, foundUser.User_id)
-		err = userCollection.FindOne(ctx, bson.M{"user_id": foundUser.User_id}).Decode(&foundUser)
+		completeLogin(ctx, c, users, foundUser)
+	}
+}
 
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+// completeLogin issues a new token pair to a user who proved who they are.
+func completeLogin(ctx context.Context, c *gin.Context, users repository.UserRepository, foundUser *models.User) {
+	if foundUser.Failed_logins > 0 || foundUser.Locked_until != nil {
+		if _, err := users.ClearFailedLogins(ctx, foundUser.User_id); err != nil {
+			response.Fail(c, err)
 			return
 		}
-		c.JSON(http.StatusOK, foundUser)
 	}
+
+	family := helper.NewTokenFamily()
+	token, refreshToken, err := helper.GenerateAllTokens(*foundUser.Email, *foundUser.Name, *foundUser.Username, *foundUser.User_type, foundUser.User_id, family, foundUser.Token_version)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+	if err := users.UpdateTokens(ctx, foundUser.User_id, token, refreshToken, family); err != nil {
+		response.Fail(c, err)
+		return
+	}
+	user, err := users.FindPrivate(ctx, foundUser.User_id)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+	response.Success(c, http.StatusOK, tokenResponse(token, refreshToken, user))
 }
 
-func GetUser() gin.HandlerFunc {
+func tokenResponse(token string, refreshToken string, user *models.PrivateUser) models.TokenResponse {
+	return models.TokenResponse{
+		Token:         token,
+		Refresh_token: refreshToken,
+		Token_type:    "Bearer",
+		Expires_in:    int(accessTTL.Seconds()),
+		User:          user,
+	}
+}
+
+// Exchange a refresh token for a new token pair
+func Refresh(users repository.UserRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		userId := c.Param("user_id")
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
 
-		if err := helper.MatchUserTypeToUid(c, userId); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		var body struct {
+			Refresh_token *string `json:"refresh_token" validate:"required"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			response.Fail(c, response.Invalid(err))
+			return
+		}
+		if validationErr := validate.Struct(&body); validationErr != nil {
+			response.Fail(c, response.Invalid(validationErr))
 			return
 		}
-		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 
-		var user models.User
-		err := userCollection.FindOne(ctx, bson.M{"user_id": userId}).Decode(&user)
-		defer cancel()
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		claims, msg := helper.ValidateRefreshToken(*body.Refresh_token)
+		if msg != "" {
+			response.Fail(c, response.Unauthorized(msg))
 			return
 		}
-		c.JSON(http.StatusOK, user)
-	}
-}
 
-func EditUser() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		user_id := c.Query("id")
-		if user_id == "" {
-			c.Header("Content-Type", "application/json")
-			c.JSON(http.StatusNotFound, gin.H{"Error": "Invalid"})
-			c.Abort()
+		foundUser, err := users.FindByRefreshFamily(ctx, claims.Family)
+		if err == nil && foundUser.User_id != claims.Subject {
+			err = repository.ErrNotFound
+		}
+		if err == repository.ErrNotFound {
+			response.Fail(c, response.Unauthorized("the refresh token has been revoked"))
 			return
 		}
-		usert_id, err := primitive.ObjectIDFromHex(user_id)
 		if err != nil {
-			c.IndentedJSON(500, err)
+			response.Fail(c, err)
+			return
 		}
-		var edituser models.User
-		if err := c.BindJSON(&edituser); err != nil {
-			c.IndentedJSON(http.StatusBadRequest, err.Error())
+
+		token, refreshToken, err := helper.GenerateAllTokens(*foundUser.Email, *foundUser.Name, *foundUser.Username, *foundUser.User_type, foundUser.User_id, claims.Family, foundUser.Token_version)
+		if err != nil {
+			response.Fail(c, err)
+			return
 		}
-		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-		defer cancel()
-		filter := bson.D{primitive.E{Key: "_id", Value: usert_id}}
-		update := bson.D{{Key: "$set",
-			Value: bson.D{primitive.E{Key: "user.name", Value: edituser.Name}}}}
-		_, err = userCollection.UpdateOne(ctx, filter, update)
+
+		rotated, err := users.RotateTokens(ctx, foundUser.User_id, *body.Refresh_token, token, refreshToken)
 		if err != nil {
-			c.IndentedJSON(500, "Something Went Wrong")
+			response.Fail(c, err)
+			return
+		}
+
+		//the token was already exchanged once, so someone is replaying it
+		if !rotated {
+			if err := users.RevokeFamily(ctx, claims.Family); err != nil {
+				response.Fail(c, err)
+				return
+			}
+			response.Fail(c, response.Unauthorized("refresh token reuse detected, please sign in again"))
 			return
 		}
+
+		response.Success(c, http.StatusOK, tokenResponse(token, refreshToken, models.NewPrivateUser(foundUser)))
+	}
+}
+
+// Sign out of the current session and invalidate every token issued so far
+func Logout(users repository.UserRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
-		ctx.Done()
-		c.IndentedJSON(200, "Successfully Updated User's Details")
+		if c.GetString("uid") == "" {
+			response.Fail(c, response.BadRequest("API keys have no session to sign out of"))
+			return
+		}
+		if err := users.RevokeAll(ctx, c.GetString("uid")); err != nil {
+			response.Fail(c, err)
+			return
+		}
+		response.Success(c, http.StatusOK, "You have been logged out")
 	}
 }
 
-//For Admin to fetch all users
-func GetUsers() gin.HandlerFunc {
+// For Admin to sign a user out of every session
+func RevokeUserSessions(users repository.UserRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if err := helper.CheckUserType(c, "ADMIN"); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		userId := c.Param("user_id")
+
+		if err := users.RevokeAll(ctx, userId); err != nil {
+			response.Fail(c, lookupError(err, "User with specified ID not found!"))
 			return
 		}
+		response.Success(c, http.StatusOK, "All sessions for the user have been revoked")
+	}
+}
+
+func GetUser(users repository.UserRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId := c.Param("user_id")
+
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
 
-		recordPerPage, err := strconv.Atoi(c.Query("recordPerPage"))
-		if err != nil || recordPerPage < 1 {
-			recordPerPage = 10
+		//other users only see the public part of an account
+		var user interface{}
+		var err error
+		if helper.MatchOwnerOr(c, userId, rbac.UserRead) == nil {
+			user, err = users.FindPrivate(ctx, userId)
+		} else {
+			user, err = users.FindPublic(ctx, userId)
 		}
-		page, err1 := strconv.Atoi(c.Query("page"))
-		if err1 != nil || page < 1 {
-			page = 1
+		if err != nil {
+			response.Fail(c, lookupError(err, "user not found"))
+			return
 		}
+		response.Success(c, http.StatusOK, user)
+	}
+}
 
-		startIndex := (page - 1) * recordPerPage
-		startIndex, err = strconv.Atoi(c.Query("startIndex"))
-
-		matchStage := bson.D{{Key: "$match", Value: bson.D{{}}}}
-		groupStage := bson.D{{Key: "$group", Value: bson.D{
-			{Key: "_id", Value: bson.D{{Key: "_id", Value: "null"}}},
-			{Key: "total_count", Value: bson.D{{Key: "$sum", Value: 1}}},
-			{Key: "data", Value: bson.D{{Key: "$push", Value: "$$ROOT"}}}}}}
-		projectStage := bson.D{
-			{Key: "$project", Value: bson.D{
-				{Key: "_id", Value: 0},
-				{Key: "total_count", Value: 1},
-				{Key: "user_items", Value: bson.D{{Key: "$slice", Value: []interface{}{"$data", startIndex, recordPerPage}}}}}}}
-		result, err := userCollection.Aggregate(ctx, mongo.Pipeline{
-			matchStage, groupStage, projectStage})
+// For Admin to fetch all users
+func GetUsers(users repository.UserRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
+
+		filter, req, err := listRequest(c, repository.UserFields)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while listing user items"})
+			response.Fail(c, err)
+			return
 		}
-		var allusers []bson.M
-		if err = result.All(ctx, &allusers); err != nil {
-			log.Fatal(err)
+		allusers, err := users.List(ctx, filter, req)
+		if err != nil {
+			response.Fail(c, err)
+			return
 		}
-		c.JSON(http.StatusOK, allusers[0])
+		response.Success(c, http.StatusOK, pageBody("user_items", allusers))
 	}
 }
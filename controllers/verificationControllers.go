@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	helper "github.com/genesdemon/golang-jwt-project/helpers"
+	"github.com/genesdemon/golang-jwt-project/mailer"
+	"github.com/genesdemon/golang-jwt-project/models"
+	"github.com/genesdemon/golang-jwt-project/repository"
+	"github.com/genesdemon/golang-jwt-project/response"
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	emailVerificationTTL        = 48 * time.Hour
+	emailVerifyURL              = ""
+	reviewsRequireVerifiedEmail = false
+)
+
+// sendVerification stores a new verification token for the user and mails it
+// to their address in the background.
+func sendVerification(ctx context.Context, users repository.UserRepository, mail mailer.Mailer, user *models.PrivateUser) error {
+	token, hash, err := helper.NewOneTimeToken()
+	if err != nil {
+		return err
+	}
+	expiresAt := time.Now().Add(emailVerificationTTL)
+	if err := users.SetVerifyToken(ctx, user.User_id, hash, expiresAt); err != nil {
+		return err
+	}
+
+	msg := verificationMessage(user, token, expiresAt)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := mail.Send(ctx, msg); err != nil {
+			log.Printf("sending email verification to %s: %v", msg.To, err)
+		}
+	}()
+	return nil
+}
+
+func verificationMessage(user *models.PrivateUser, token string, expiresAt time.Time) mailer.Message {
+	instructions := "Use this token to verify it:\n\n" + token
+	if emailVerifyURL != "" {
+		instructions = "Follow this link to verify it:\n\n" + emailVerifyURL + "?token=" + url.QueryEscape(token)
+	}
+	return mailer.Message{
+		To:      *user.Email,
+		Subject: "Verify your Shive email",
+		Body: fmt.Sprintf("Hello %s,\n\nThis email was given for a Shive account. %s\n\nIt can be used until %s. If this was not you, you can ignore this email.\n",
+			*user.Name, instructions, expiresAt.UTC().Format(time.RFC1123)),
+	}
+}
+
+// Verify the email of an account with the token sent to it
+func VerifyEmail(users repository.UserRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		token := c.Query("token")
+		if token == "" {
+			response.Fail(c, response.BadRequest("the token query parameter is required"))
+			return
+		}
+		_, err := users.ConsumeVerifyToken(ctx, helper.HashOneTimeToken(token), time.Now())
+		if err == repository.ErrNotFound {
+			response.Fail(c, response.BadRequest("the verification token is invalid, expired or already used"))
+			return
+		}
+		if err != nil {
+			response.Fail(c, err)
+			return
+		}
+		response.Success(c, http.StatusOK, "Your email has been verified")
+	}
+}
+
+// Send a new verification token to the email of the signed in user
+func ResendVerification(users repository.UserRepository, mail mailer.Mailer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if c.GetString("uid") == "" {
+			response.Fail(c, response.BadRequest("API keys have no email to verify"))
+			return
+		}
+
+		user, err := users.FindByUserID(ctx, c.GetString("uid"))
+		if err != nil {
+			response.Fail(c, lookupError(err, "User with specified ID not found!"))
+			return
+		}
+		if user.Email_verified {
+			response.Fail(c, response.Conflict("this email is already verified"))
+			return
+		}
+		if err := sendVerification(ctx, users, mail, models.NewPrivateUser(user)); err != nil {
+			response.Fail(c, err)
+			return
+		}
+		response.Success(c, http.StatusAccepted, "A verification link has been sent to your email")
+	}
+}
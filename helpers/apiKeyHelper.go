@@ -0,0 +1,103 @@
+package helper
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/genesdemon/golang-jwt-project/models"
+	"github.com/genesdemon/golang-jwt-project/repository"
+)
+
+var (
+	ErrInvalidAPIKey = errors.New("the API key is invalid")
+	ErrAPIKeyRevoked = errors.New("the API key has been revoked")
+	ErrAPIKeyExpired = errors.New("the API key has expired")
+)
+
+// APIKeyPrefix starts every API key, which tells them apart from JWTs and
+// makes leaked keys easy to scan for.
+const APIKeyPrefix = "shive_"
+
+// NewAPIKey returns a fresh key, the public ID it is looked up by and the hash
+// of its secret to store. The key itself is not kept anywhere.
+func NewAPIKey() (key string, keyId string, hash string, err error) {
+	idBytes := make([]byte, 8)
+	secretBytes := make([]byte, 32)
+	if _, err = rand.Read(idBytes); err != nil {
+		return
+	}
+	if _, err = rand.Read(secretBytes); err != nil {
+		return
+	}
+	keyId = hex.EncodeToString(idBytes)
+	secret := base64.RawURLEncoding.EncodeToString(secretBytes)
+	return APIKeyPrefix + keyId + "_" + secret, keyId, HashAPIKeySecret(secret), nil
+}
+
+// IsAPIKey reports whether credential has the shape of an API key.
+func IsAPIKey(credential string) bool {
+	return strings.HasPrefix(credential, APIKeyPrefix)
+}
+
+// ParseAPIKey splits a key into its public ID and its secret.
+func ParseAPIKey(key string) (keyId string, secret string, ok bool) {
+	if !IsAPIKey(key) {
+		return "", "", false
+	}
+	keyId, secret, ok = strings.Cut(strings.TrimPrefix(key, APIKeyPrefix), "_")
+	return keyId, secret, ok && keyId != "" && secret != ""
+}
+
+// HashAPIKeySecret hashes the secret part of a key. The secret is random and
+// long, so a fast hash is enough.
+func HashAPIKeySecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// MatchAPIKeySecret compares secret with a stored hash in constant time.
+func MatchAPIKeySecret(secret string, hash string) bool {
+	return subtle.ConstantTimeCompare([]byte(HashAPIKeySecret(secret)), []byte(hash)) == 1
+}
+
+// CheckAPIKey returns the stored key matching key when it is still usable.
+// Only a caller holding the right secret learns whether a key was revoked or
+// has expired; anything else is ErrInvalidAPIKey.
+func CheckAPIKey(ctx context.Context, keys repository.APIKeyRepository, key string) (*models.APIKey, error) {
+	keyId, secret, ok := ParseAPIKey(key)
+	if !ok {
+		return nil, ErrInvalidAPIKey
+	}
+	found, err := keys.FindByKeyID(ctx, keyId)
+	if err == repository.ErrNotFound {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !MatchAPIKeySecret(secret, found.Hash) {
+		return nil, ErrInvalidAPIKey
+	}
+	if found.Revoked_at != nil {
+		return nil, ErrAPIKeyRevoked
+	}
+	if found.Expires_at != nil && !time.Now().Before(*found.Expires_at) {
+		return nil, ErrAPIKeyExpired
+	}
+
+	//recording every single use would be a write per request
+	if found.Last_used_at == nil || time.Since(*found.Last_used_at) > time.Minute {
+		now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		if err := keys.Touch(ctx, keyId, now); err != nil {
+			return nil, err
+		}
+	}
+	return found, nil
+}
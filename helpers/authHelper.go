@@ -6,25 +6,26 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func CheckUserType(c *gin.Context, role string) (err error) {
-	userType := c.GetString("user_type")
-	err = nil
-	if userType != role {
-		err = errors.New("Unauthorized to access this resource")
-		return err
-	}
-	return err
-}
+// PermissionsKey is the gin context key Authenticate stores the caller's
+// permission set under.
+const PermissionsKey = "permissions"
 
-func MatchUserTypeToUid(c *gin.Context, userId string) (err error) {
-	userType := c.GetString("user_type")
-	uid := c.GetString("uid")
-	err = nil
+var ErrForbidden = errors.New("Unauthorized to access this resource")
 
-	if userType == "USER" && uid != userId {
-		err = errors.New("Unauthorized to access this resource")
-		return err
+// HasPermission reports whether the signed in caller holds permission.
+func HasPermission(c *gin.Context, permission string) bool {
+	granted, _ := c.Value(PermissionsKey).(map[string]bool)
+	return granted[permission]
+}
+
+// MatchOwnerOr allows the request when the caller owns the resource or holds
+// permission.
+func MatchOwnerOr(c *gin.Context, ownerId string, permission string) error {
+	if HasPermission(c, permission) {
+		return nil
+	}
+	if ownerId == "" || c.GetString("uid") != ownerId {
+		return ErrForbidden
 	}
-	err = CheckUserType(c, userType)
-	return err
+	return nil
 }
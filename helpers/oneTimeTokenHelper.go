@@ -0,0 +1,25 @@
+package helper
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// NewOneTimeToken returns a random token to hand to a user, for example in a
+// password reset email, and the hash of it to store instead.
+func NewOneTimeToken() (token string, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err = rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, HashOneTimeToken(token), nil
+}
+
+// HashOneTimeToken hashes a token from NewOneTimeToken so it can be looked up.
+func HashOneTimeToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,32 @@
+package helper
+
+import (
+	"context"
+	"errors"
+
+	"github.com/genesdemon/golang-jwt-project/models"
+	"github.com/genesdemon/golang-jwt-project/repository"
+)
+
+var ErrSessionRevoked = errors.New("this session has been revoked, please sign in again")
+
+// CheckTokenVersion makes sure the access token was issued for the user's
+// current token version, i.e. it has not been revoked by a logout, and that
+// the session of its refresh token family, if it has one, has not ended. It
+// returns the user the token belongs to.
+func CheckTokenVersion(ctx context.Context, users repository.UserRepository, userId string, version int, family string) (*models.User, error) {
+	user, err := users.FindByUserID(ctx, userId)
+	if err == repository.ErrNotFound {
+		return nil, ErrSessionRevoked
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.Token_version != version {
+		return nil, ErrSessionRevoked
+	}
+	if family != "" && !user.HasSession(family) {
+		return nil, ErrSessionRevoked
+	}
+	return user, nil
+}
@@ -0,0 +1,381 @@
+package helper
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/genesdemon/golang-jwt-project/config"
+	"github.com/genesdemon/golang-jwt-project/models"
+	"github.com/genesdemon/golang-jwt-project/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// signingMethodEdDSA signs tokens with Ed25519 keys, which jwt-go does not
+// support on its own.
+type signingMethodEdDSA struct{}
+
+// SigningMethodEdDSA is registered with jwt-go under the "EdDSA" alg.
+var SigningMethodEdDSA = &signingMethodEdDSA{}
+
+func init() {
+	jwt.RegisterSigningMethod(SigningMethodEdDSA.Alg(), func() jwt.SigningMethod { return SigningMethodEdDSA })
+}
+
+func (m *signingMethodEdDSA) Alg() string {
+	return config.AlgorithmEdDSA
+}
+
+func (m *signingMethodEdDSA) Sign(signingString string, key interface{}) (string, error) {
+	private, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return "", jwt.ErrInvalidKeyType
+	}
+	return jwt.EncodeSegment(ed25519.Sign(private, []byte(signingString))), nil
+}
+
+func (m *signingMethodEdDSA) Verify(signingString string, signature string, key interface{}) error {
+	public, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return jwt.ErrInvalidKeyType
+	}
+	sig, err := jwt.DecodeSegment(signature)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(public, []byte(signingString), sig) {
+		return jwt.ErrSignatureInvalid
+	}
+	return nil
+}
+
+// keyReload is how long loaded keys are trusted before they are read again,
+// so keys created by another instance are picked up.
+const keyReload = time.Minute
+
+// keyring caches the unexpired signing keys and rotates them on schedule.
+type keyring struct {
+	mu       sync.Mutex
+	keys     repository.SigningKeyRepository
+	loaded   []parsedKey
+	loadedAt time.Time
+}
+
+type parsedKey struct {
+	models.SigningKey
+	private interface{}
+	public  interface{}
+}
+
+var signingKeys *keyring
+
+// UseSigningKeys loads the stored signing keys, creating the first one when an
+// asymmetric algorithm is configured. It must be called after Configure.
+// Keys stay in use for verification even after switching back to HS256.
+func UseSigningKeys(ctx context.Context, keys repository.SigningKeyRepository) error {
+	signingKeys = &keyring{keys: keys}
+	if tokenConfig.Algorithm == config.AlgorithmHS256 {
+		return signingKeys.reload(ctx, time.Now())
+	}
+	_, err := signingKeys.current(ctx, time.Now())
+	return err
+}
+
+func (k *keyring) reload(ctx context.Context, now time.Time) error {
+	if _, err := k.keys.DeleteExpired(ctx, now); err != nil {
+		return err
+	}
+	stored, err := k.keys.ListUnexpired(ctx, now)
+	if err != nil {
+		return err
+	}
+	loaded := make([]parsedKey, 0, len(stored))
+	for _, key := range stored {
+		//keys older versions stored in the clear are encrypted once a key
+		//encryption key is configured
+		if key.Private_key_encryption == "" && tokenConfig.KeyEncryptionKey != "" {
+			sealed, err := sealPrivateKey(key.Kid, key.Private_key)
+			if err != nil {
+				return fmt.Errorf("signing key %s: %w", key.Kid, err)
+			}
+			if err := k.keys.SetPrivateKey(ctx, key.Kid, sealed, privateKeyEncryption); err != nil {
+				return fmt.Errorf("signing key %s: %w", key.Kid, err)
+			}
+			key.Private_key, key.Private_key_encryption = sealed, privateKeyEncryption
+		}
+		parsed, err := parseKey(key)
+		if err != nil {
+			return fmt.Errorf("signing key %s: %w", key.Kid, err)
+		}
+		loaded = append(loaded, parsed)
+	}
+	k.loaded = loaded
+	k.loadedAt = now
+	return nil
+}
+
+// current returns the key to sign with now. The next key is created a tenth
+// of the rotation period before it takes over, so verifiers see it in the
+// JWKS before any token uses it.
+func (k *keyring) current(ctx context.Context, now time.Time) (*parsedKey, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if now.Sub(k.loadedAt) > keyReload {
+		if err := k.reload(ctx, now); err != nil {
+			return nil, err
+		}
+	}
+
+	active := k.active(now)
+	if active == nil {
+		if err := k.create(ctx, now, now); err != nil {
+			return nil, err
+		}
+		active = k.active(now)
+	}
+	if active.Retires_at.Sub(now) < tokenConfig.KeyRotation/10 && k.active(active.Retires_at) == nil {
+		if err := k.create(ctx, now, active.Retires_at); err != nil {
+			return nil, err
+		}
+		active = k.active(now)
+	}
+	if active == nil {
+		return nil, errors.New("no signing key is active")
+	}
+	return active, nil
+}
+
+// active returns the latest key of the configured algorithm signing at the
+// given time, if any.
+func (k *keyring) active(at time.Time) *parsedKey {
+	for i := len(k.loaded) - 1; i >= 0; i-- {
+		key := &k.loaded[i]
+		if key.Algorithm == tokenConfig.Algorithm && key.private != nil && !at.Before(key.Activates_at) && at.Before(key.Retires_at) {
+			return key
+		}
+	}
+	return nil
+}
+
+func (k *keyring) create(ctx context.Context, now time.Time, activates time.Time) error {
+	private, public, err := generateKeyPair(tokenConfig.Algorithm)
+	if err != nil {
+		return err
+	}
+	kid := primitive.NewObjectID().Hex()
+	sealed, err := sealPrivateKey(kid, private)
+	if err != nil {
+		return err
+	}
+	retires := activates.Add(tokenConfig.KeyRotation)
+	key := models.SigningKey{
+		Id:                     primitive.NewObjectID(),
+		Kid:                    kid,
+		Algorithm:              tokenConfig.Algorithm,
+		Private_key:            sealed,
+		Private_key_encryption: privateKeyEncryption,
+		Public_key:             public,
+		Created_at:             now,
+		Activates_at:           activates,
+		Retires_at:             retires,
+		//the last token signed must still verify until it expires
+		Expires_at: retires.Add(tokenConfig.RefreshTTL),
+	}
+	if err := k.keys.Create(ctx, &key); err != nil {
+		return err
+	}
+	return k.reload(ctx, now)
+}
+
+// verifying returns the key with the given kid, reading the stored keys again
+// when it is unknown in case another instance just created it.
+func (k *keyring) verifying(ctx context.Context, kid string) (*parsedKey, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	now := time.Now()
+	find := func() *parsedKey {
+		for i := range k.loaded {
+			if k.loaded[i].Kid == kid && now.Before(k.loaded[i].Expires_at) {
+				return &k.loaded[i]
+			}
+		}
+		return nil
+	}
+	if key := find(); key != nil {
+		return key, nil
+	}
+	if now.Sub(k.loadedAt) > 10*time.Second {
+		if err := k.reload(ctx, now); err != nil {
+			return nil, err
+		}
+		if key := find(); key != nil {
+			return key, nil
+		}
+	}
+	return nil, errors.New("the token was signed with an unknown key")
+}
+
+func (k *keyring) published(ctx context.Context) ([]parsedKey, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	now := time.Now()
+	if now.Sub(k.loadedAt) > keyReload {
+		if err := k.reload(ctx, now); err != nil {
+			return nil, err
+		}
+	}
+	return append([]parsedKey{}, k.loaded...), nil
+}
+
+func generateKeyPair(algorithm string) (private []byte, public []byte, err error) {
+	var privateKey, publicKey interface{}
+	switch algorithm {
+	case config.AlgorithmRS256:
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, nil, err
+		}
+		privateKey, publicKey = key, &key.PublicKey
+	case config.AlgorithmEdDSA:
+		pub, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, nil, err
+		}
+		privateKey, publicKey = key, pub
+	default:
+		return nil, nil, fmt.Errorf("cannot generate keys for %s", algorithm)
+	}
+	if private, err = x509.MarshalPKCS8PrivateKey(privateKey); err != nil {
+		return nil, nil, err
+	}
+	public, err = x509.MarshalPKIXPublicKey(publicKey)
+	return private, public, err
+}
+
+// parseKey decodes a stored key. Without a key encryption key only the public
+// half is available, which is all verifying tokens takes.
+func parseKey(key models.SigningKey) (parsedKey, error) {
+	public, err := x509.ParsePKIXPublicKey(key.Public_key)
+	if err != nil {
+		return parsedKey{}, err
+	}
+	parsed := parsedKey{SigningKey: key, public: public}
+
+	plain := key.Private_key
+	switch key.Private_key_encryption {
+	case privateKeyEncryption:
+		if tokenConfig.KeyEncryptionKey == "" {
+			return parsed, nil
+		}
+		if plain, err = openPrivateKey(key.Kid, key.Private_key); err != nil {
+			return parsedKey{}, err
+		}
+	case "":
+	default:
+		return parsedKey{}, fmt.Errorf("unknown private key encryption %q", key.Private_key_encryption)
+	}
+	if parsed.private, err = x509.ParsePKCS8PrivateKey(plain); err != nil {
+		return parsedKey{}, err
+	}
+	return parsed, nil
+}
+
+// privateKeyEncryption is the only way private keys are encrypted at rest:
+// AES-256-GCM under the configured key encryption key, with the kid as
+// additional data so a sealed key cannot be moved to another document.
+const privateKeyEncryption = "A256GCM"
+
+func keyEncryptionCipher() (cipher.AEAD, error) {
+	kek, err := base64.StdEncoding.DecodeString(tokenConfig.KeyEncryptionKey)
+	if err != nil || len(kek) != 32 {
+		return nil, errors.New("the key encryption key must be 32 bytes encoded in base64")
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealPrivateKey encrypts a private key for storage, prefixed with its nonce.
+func sealPrivateKey(kid string, private []byte) ([]byte, error) {
+	aead, err := keyEncryptionCipher()
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, private, []byte(kid)), nil
+}
+
+func openPrivateKey(kid string, sealed []byte) ([]byte, error) {
+	aead, err := keyEncryptionCipher()
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("the private key is truncated")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	private, err := aead.Open(nil, nonce, ciphertext, []byte(kid))
+	if err != nil {
+		return nil, errors.New("the private key cannot be decrypted with the configured key encryption key")
+	}
+	return private, nil
+}
+
+// JWK is a public signing key in JSON Web Key form (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS returns the public keys tokens may be verified with, including the
+// next key before it starts signing.
+func JWKS(ctx context.Context) ([]JWK, error) {
+	jwks := []JWK{}
+	if signingKeys == nil {
+		return jwks, nil
+	}
+	keys, err := signingKeys.published(ctx)
+	if err != nil {
+		return nil, err
+	}
+	encode := base64.RawURLEncoding.EncodeToString
+	for _, key := range keys {
+		jwk := JWK{Kid: key.Kid, Use: "sig", Alg: key.Algorithm}
+		switch public := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = encode(public.N.Bytes())
+			jwk.E = encode(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = encode(public)
+		default:
+			continue
+		}
+		jwks = append(jwks, jwk)
+	}
+	return jwks, nil
+}
@@ -0,0 +1,233 @@
+package helper
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/genesdemon/golang-jwt-project/config"
+	"github.com/genesdemon/golang-jwt-project/models"
+	"github.com/genesdemon/golang-jwt-project/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var testKeyEncryptionKey = base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+
+func configureKeys(t *testing.T, algorithm string) repository.SigningKeyRepository {
+	t.Helper()
+	cfg := config.Default().JWT
+	cfg.Algorithm = algorithm
+	cfg.KeyRotation = time.Hour
+	cfg.KeyEncryptionKey = testKeyEncryptionKey
+	Configure(cfg)
+	keys := repository.NewMemoryStore().SigningKeys
+	if err := UseSigningKeys(context.Background(), keys); err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+func TestKeyringRotation(t *testing.T) {
+	for _, algorithm := range []string{config.AlgorithmRS256, config.AlgorithmEdDSA} {
+		t.Run(algorithm, func(t *testing.T) {
+			keys := configureKeys(t, algorithm)
+			ctx := context.Background()
+			start := time.Now()
+
+			first, err := signingKeys.current(ctx, start)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if first.Algorithm != algorithm {
+				t.Fatalf("key algorithm = %s, want %s", first.Algorithm, algorithm)
+			}
+
+			tests := []struct {
+				name       string
+				at         time.Duration
+				wantFirst  bool
+				wantStored int
+			}{
+				{"same key while active", 30 * time.Minute, true, 1},
+				//a tenth of the rotation before retiring, the next key is
+				//created but does not sign yet
+				{"next key created early", 55 * time.Minute, true, 2},
+				{"next key takes over", 61 * time.Minute, false, 2},
+			}
+			for _, tt := range tests {
+				key, err := signingKeys.current(ctx, start.Add(tt.at))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if (key.Kid == first.Kid) != tt.wantFirst {
+					t.Errorf("%s: signing kid %s, first kid %s", tt.name, key.Kid, first.Kid)
+				}
+				stored, err := keys.ListUnexpired(ctx, start)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(stored) != tt.wantStored {
+					t.Errorf("%s: %d stored keys, want %d", tt.name, len(stored), tt.wantStored)
+				}
+			}
+		})
+	}
+}
+
+func TestKeyringLooksUpKeysByKid(t *testing.T) {
+	configureKeys(t, config.AlgorithmEdDSA)
+	ctx := context.Background()
+	start := time.Now()
+	first, err := signingKeys.current(ctx, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := signingKeys.current(ctx, start.Add(61*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, kid := range []string{first.Kid, second.Kid} {
+		key, err := signingKeys.verifying(ctx, kid)
+		if err != nil {
+			t.Fatalf("kid %s: %v", kid, err)
+		}
+		if key.Kid != kid {
+			t.Errorf("looked up kid %s, got %s", kid, key.Kid)
+		}
+	}
+	if _, err := signingKeys.verifying(ctx, primitive.NewObjectID().Hex()); err == nil {
+		t.Error("an unknown kid was found")
+	}
+
+	jwks, err := JWKS(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jwks) != 2 || jwks[0].Kid != first.Kid || jwks[1].Kid != second.Kid || jwks[0].Kty != "OKP" {
+		t.Errorf("jwks = %+v", jwks)
+	}
+}
+
+func TestTokensCarryTheKidTheyVerifyWith(t *testing.T) {
+	configureKeys(t, config.AlgorithmRS256)
+	access, _, err := GenerateAllTokens("a@x.io", "A", "alice", "USER", "uid-1", NewTokenFamily(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, _ := jwt.Parse(access, nil)
+	kid, _ := token.Header["kid"].(string)
+	if key, err := signingKeys.verifying(context.Background(), kid); err != nil || key.Kid != kid {
+		t.Fatalf("kid %q of the token is not a stored key: %v", kid, err)
+	}
+	if _, msg := ValidateToken(access); msg != "" {
+		t.Fatal(msg)
+	}
+
+	//an HS256 token must not verify once keys are in use
+	forged, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, &SignedDetails{}).SignedString([]byte(""))
+	if _, msg := ValidateToken(forged); msg == "" {
+		t.Error("an HS256 token without a secret was accepted")
+	}
+}
+
+func TestPrivateKeysAreEncryptedAtRest(t *testing.T) {
+	keys := configureKeys(t, config.AlgorithmEdDSA)
+	ctx := context.Background()
+	stored, err := keys.ListUnexpired(ctx, time.Now())
+	if err != nil || len(stored) != 1 {
+		t.Fatalf("stored keys = %d, %v", len(stored), err)
+	}
+	key := stored[0]
+	if key.Private_key_encryption != privateKeyEncryption {
+		t.Fatalf("private key encryption = %q", key.Private_key_encryption)
+	}
+	if _, err := x509.ParsePKCS8PrivateKey(key.Private_key); err == nil {
+		t.Fatal("the stored private key is readable without the key encryption key")
+	}
+
+	tests := []struct {
+		name    string
+		mutate  func(k *models.SigningKey)
+		kek     string
+		wantErr bool
+	}{
+		{"right key", func(k *models.SigningKey) {}, testKeyEncryptionKey, false},
+		{"wrong key", func(k *models.SigningKey) {}, base64.StdEncoding.EncodeToString(make([]byte, 32)), true},
+		{"moved to another kid", func(k *models.SigningKey) { k.Kid = "other" }, testKeyEncryptionKey, true},
+		{"tampered", func(k *models.SigningKey) { k.Private_key[len(k.Private_key)-1] ^= 1 }, testKeyEncryptionKey, true},
+		{"unknown encryption", func(k *models.SigningKey) { k.Private_key_encryption = "ROT13" }, testKeyEncryptionKey, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := key
+			k.Private_key = append([]byte{}, key.Private_key...)
+			tt.mutate(&k)
+			tokenConfig.KeyEncryptionKey = tt.kek
+			defer func() { tokenConfig.KeyEncryptionKey = testKeyEncryptionKey }()
+			parsed, err := parseKey(k)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && parsed.private == nil {
+				t.Error("the private key was not loaded")
+			}
+		})
+	}
+
+	//without a key encryption key only the public half is loaded
+	tokenConfig.KeyEncryptionKey = ""
+	defer func() { tokenConfig.KeyEncryptionKey = testKeyEncryptionKey }()
+	parsed, err := parseKey(key)
+	if err != nil || parsed.private != nil || parsed.public == nil {
+		t.Errorf("parsed without a key encryption key: private %v, public %v, err %v", parsed.private != nil, parsed.public != nil, err)
+	}
+}
+
+func TestKeysStoredInTheClearAreEncryptedOnLoad(t *testing.T) {
+	keys := configureKeys(t, config.AlgorithmEdDSA)
+	ctx := context.Background()
+	private, public, err := generateKeyPair(config.AlgorithmEdDSA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	legacy := models.SigningKey{
+		Id:           primitive.NewObjectID(),
+		Kid:          "legacy",
+		Algorithm:    config.AlgorithmEdDSA,
+		Private_key:  private,
+		Public_key:   public,
+		Activates_at: now.Add(-time.Hour),
+		Retires_at:   now.Add(-time.Minute),
+		Expires_at:   now.Add(time.Hour),
+	}
+	if err := keys.Create(ctx, &legacy); err != nil {
+		t.Fatal(err)
+	}
+	if err := signingKeys.reload(ctx, now); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := keys.ListUnexpired(ctx, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range stored {
+		if key.Kid != "legacy" {
+			continue
+		}
+		if key.Private_key_encryption != privateKeyEncryption || strings.Contains(string(key.Private_key), string(private)) {
+			t.Fatal("the legacy key is still stored in the clear")
+		}
+		if _, err := signingKeys.verifying(ctx, "legacy"); err != nil {
+			t.Fatalf("the legacy key no longer verifies: %v", err)
+		}
+		return
+	}
+	t.Fatal("the legacy key is gone")
+}
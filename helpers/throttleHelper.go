@@ -0,0 +1,116 @@
+package helper
+
+import (
+	"sync"
+	"time"
+
+	"github.com/genesdemon/golang-jwt-project/config"
+)
+
+// Backoff decides how long to lock out after a number of failures in a row.
+type Backoff struct {
+	MaxAttempts int
+	Lockout     time.Duration
+	MaxLockout  time.Duration
+}
+
+// AccountBackoff and IPBackoff build the backoff for one account and for one
+// client address from the login settings.
+func AccountBackoff(cfg config.LoginConfig) Backoff {
+	return Backoff{MaxAttempts: cfg.MaxAttempts, Lockout: cfg.Lockout, MaxLockout: cfg.MaxLockout}
+}
+
+func IPBackoff(cfg config.LoginConfig) Backoff {
+	return Backoff{MaxAttempts: cfg.MaxAttemptsPerIP, Lockout: cfg.Lockout, MaxLockout: cfg.MaxLockout}
+}
+
+// LockFor returns the lockout earned by the given number of failures: none
+// below MaxAttempts, then Lockout doubling with every failure up to
+// MaxLockout.
+func (b Backoff) LockFor(failures int) time.Duration {
+	if failures < b.MaxAttempts {
+		return 0
+	}
+	lock := b.Lockout
+	for i := b.MaxAttempts; i < failures && lock < b.MaxLockout; i++ {
+		lock *= 2
+	}
+	if lock > b.MaxLockout {
+		lock = b.MaxLockout
+	}
+	return lock
+}
+
+// Window is how long failures are remembered after the last one.
+func (b Backoff) Window() time.Duration {
+	return b.MaxLockout
+}
+
+// Throttle counts failures per key, such as a client address, in process
+// memory and locks keys out with a Backoff.
+type Throttle struct {
+	mu      sync.Mutex
+	backoff Backoff
+	entries map[string]*throttleEntry
+	pruned  time.Time
+}
+
+type throttleEntry struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+func NewThrottle(backoff Backoff) *Throttle {
+	return &Throttle{backoff: backoff, entries: map[string]*throttleEntry{}}
+}
+
+// Wait returns how much longer key is locked out, or zero.
+func (t *Throttle) Wait(key string, now time.Time) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	entry, ok := t.entries[key]
+	if !ok || !now.Before(entry.lockedUntil) {
+		return 0
+	}
+	return entry.lockedUntil.Sub(now)
+}
+
+// Fail records a failure for key and returns the lockout it earned, if any.
+func (t *Throttle) Fail(key string, now time.Time) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.prune(now)
+	entry, ok := t.entries[key]
+	if !ok || now.Sub(entry.lastFailure) > t.backoff.Window() {
+		entry = &throttleEntry{}
+		t.entries[key] = entry
+	}
+	entry.failures++
+	entry.lastFailure = now
+	lock := t.backoff.LockFor(entry.failures)
+	if lock > 0 {
+		entry.lockedUntil = now.Add(lock)
+	}
+	return lock
+}
+
+// Reset forgets the failures of key.
+func (t *Throttle) Reset(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.entries, key)
+}
+
+// prune drops keys whose failures are forgotten, at most once a minute.
+func (t *Throttle) prune(now time.Time) {
+	if now.Sub(t.pruned) < time.Minute {
+		return
+	}
+	t.pruned = now
+	for key, entry := range t.entries {
+		if now.Sub(entry.lastFailure) > t.backoff.Window() && !now.Before(entry.lockedUntil) {
+			delete(t.entries, key)
+		}
+	}
+}
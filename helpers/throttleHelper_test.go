@@ -0,0 +1,92 @@
+package helper
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoffLockFor(t *testing.T) {
+	backoff := Backoff{MaxAttempts: 3, Lockout: time.Minute, MaxLockout: 10 * time.Minute}
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{2, 0},
+		{3, time.Minute},
+		{4, 2 * time.Minute},
+		{5, 4 * time.Minute},
+		{6, 8 * time.Minute},
+		{7, 10 * time.Minute},
+		{50, 10 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := backoff.LockFor(tt.failures); got != tt.want {
+			t.Errorf("LockFor(%d) = %s, want %s", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	backoff := Backoff{MaxAttempts: 2, Lockout: time.Minute, MaxLockout: 4 * time.Minute}
+	start := time.Unix(1700000000, 0)
+	at := func(d time.Duration) time.Time { return start.Add(d) }
+
+	type step struct {
+		fail     bool
+		reset    bool
+		at       time.Duration
+		wantLock time.Duration
+		wantWait time.Duration
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"below the limit", []step{
+			{fail: true, at: 0, wantLock: 0, wantWait: 0},
+		}},
+		{"locked at the limit and doubling", []step{
+			{fail: true, at: 0},
+			{fail: true, at: time.Second, wantLock: time.Minute, wantWait: time.Minute},
+			{at: 31 * time.Second, wantWait: 30 * time.Second},
+			{fail: true, at: 2 * time.Minute, wantLock: 2 * time.Minute, wantWait: 2 * time.Minute},
+		}},
+		{"lock runs out", []step{
+			{fail: true, at: 0},
+			{fail: true, at: 0, wantLock: time.Minute, wantWait: time.Minute},
+			{at: time.Minute, wantWait: 0},
+		}},
+		{"failures are forgotten after the window", []step{
+			{fail: true, at: 0},
+			{fail: true, at: 5 * time.Minute, wantLock: 0},
+		}},
+		{"reset forgets failures", []step{
+			{fail: true, at: 0},
+			{reset: true},
+			{fail: true, at: time.Second, wantLock: 0},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			throttle := NewThrottle(backoff)
+			for i, s := range tt.steps {
+				if s.reset {
+					throttle.Reset("key")
+					continue
+				}
+				if s.fail {
+					if lock := throttle.Fail("key", at(s.at)); lock != s.wantLock {
+						t.Fatalf("step %d: Fail = %s, want %s", i, lock, s.wantLock)
+					}
+				}
+				if wait := throttle.Wait("key", at(s.at)); wait != s.wantWait {
+					t.Fatalf("step %d: Wait = %s, want %s", i, wait, s.wantWait)
+				}
+				if wait := throttle.Wait("other", at(s.at)); wait != 0 {
+					t.Fatalf("step %d: another key waits %s", i, wait)
+				}
+			}
+		})
+	}
+}
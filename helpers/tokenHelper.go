@@ -2,17 +2,13 @@ package helper
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
-	"os"
 	"time"
 
 	jwt "github.com/dgrijalva/jwt-go"
-	"github.com/genesdemon/golang-jwt-project/database"
-	"go.mongodb.org/mongo-driver/bson"
+	"github.com/genesdemon/golang-jwt-project/config"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type SignedDetails struct {
@@ -21,49 +17,190 @@ type SignedDetails struct {
 	Username  string
 	Uid       string
 	User_type string
+	Family    string
+	Version   int
+	// Type tells access, refresh and MFA challenge tokens apart, so none is
+	// accepted where another is expected.
+	Type string
 	jwt.StandardClaims
 }
 
-var userCollection *mongo.Collection = database.OpenCollection(database.Client, "user")
+// Token types.
+const (
+	AccessToken       = "access"
+	RefreshToken      = "refresh"
+	MFAChallengeToken = "mfa_challenge"
+)
+
+// Valid checks the standard claims, allowing for the configured clock skew
+// between the servers issuing and checking tokens. jwt-go calls it while
+// parsing.
+func (c *SignedDetails) Valid() error {
+	now := time.Now()
+	skew := tokenConfig.ClockSkew
+	if !c.VerifyExpiresAt(now.Add(-skew).Unix(), true) {
+		return errors.New("token is expired")
+	}
+	if !c.VerifyIssuedAt(now.Add(skew).Unix(), true) || !c.VerifyNotBefore(now.Add(skew).Unix(), true) {
+		return errors.New("token is not valid yet")
+	}
+	if c.IssuedAt == 0 || c.NotBefore == 0 {
+		return errors.New("token is missing its iat or nbf claim")
+	}
+	if !c.VerifyIssuer(tokenConfig.Issuer, true) {
+		return errors.New("token was issued by someone else")
+	}
+	if !c.VerifyAudience(tokenConfig.Audience, true) {
+		return errors.New("token is not meant for this audience")
+	}
+	if c.Subject == "" || c.Id == "" {
+		return errors.New("token is missing its sub or jti claim")
+	}
+	return nil
+}
+
+var tokenConfig config.JWTConfig
+
+// Configure sets the algorithm, secret, issuer and lifetimes used to sign
+// tokens. It must be called before any token is generated or validated.
+func Configure(cfg config.JWTConfig) {
+	tokenConfig = cfg
+}
 
-var SECRET_KEY string = os.Getenv("SECRET_KEY")
+// NewTokenFamily returns a fresh identifier for a chain of rotated refresh tokens.
+func NewTokenFamily() string {
+	return primitive.NewObjectID().Hex()
+}
 
-func GenerateAllTokens(email string, name string, userName string, userType string, uid string) (signedToken string, signedRefreshToken string, err error) {
+func GenerateAllTokens(email string, name string, userName string, userType string, uid string, family string, version int) (signedToken string, signedRefreshToken string, err error) {
+	now := time.Now()
 	claims := &SignedDetails{
-		Email:     email,
-		Name:      name,
-		Username:  userName,
-		Uid:       uid,
-		User_type: userType,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Local().Add(time.Hour * time.Duration(24)).Unix(),
-		},
+		Email:          email,
+		Name:           name,
+		Username:       userName,
+		Uid:            uid,
+		User_type:      userType,
+		Family:         family,
+		Version:        version,
+		Type:           AccessToken,
+		StandardClaims: standardClaims(uid, now, tokenConfig.AccessTTL),
 	}
 
 	refreshClaims := &SignedDetails{
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Local().Add(time.Hour * time.Duration(168)).Unix(),
-		},
+		Family:         family,
+		Type:           RefreshToken,
+		StandardClaims: standardClaims(uid, now, tokenConfig.RefreshTTL),
 	}
 
-	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(SECRET_KEY))
-	refreshToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims).SignedString([]byte(SECRET_KEY))
+	token, err := sign(claims)
+	if err != nil {
+		return "", "", err
+	}
+	refreshToken, err := sign(refreshClaims)
+	if err != nil {
+		return "", "", err
+	}
+	return token, refreshToken, nil
+}
+
+// GenerateMFAChallenge returns the short lived token that the first sign in
+// step of an account with two-factor authentication hands out. Only a valid
+// code turns it into real tokens.
+func GenerateMFAChallenge(uid string, version int) (string, error) {
+	return sign(&SignedDetails{
+		Version:        version,
+		Type:           MFAChallengeToken,
+		StandardClaims: standardClaims(uid, time.Now(), tokenConfig.ChallengeTTL),
+	})
+}
 
+func standardClaims(uid string, now time.Time, ttl time.Duration) jwt.StandardClaims {
+	return jwt.StandardClaims{
+		Id:        primitive.NewObjectID().Hex(),
+		Subject:   uid,
+		Issuer:    tokenConfig.Issuer,
+		Audience:  tokenConfig.Audience,
+		IssuedAt:  now.Unix(),
+		NotBefore: now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+	}
+}
+
+// sign signs claims with the secret, or with the current key and its kid when
+// an asymmetric algorithm is configured.
+func sign(claims jwt.Claims) (string, error) {
+	if tokenConfig.Algorithm == config.AlgorithmHS256 {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(tokenConfig.Secret))
+	}
+	if signingKeys == nil {
+		return "", errors.New("signing keys have not been loaded")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	key, err := signingKeys.current(ctx, time.Now())
 	if err != nil {
-		log.Panic(err)
-		return
+		return "", err
 	}
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(key.Algorithm), claims)
+	token.Header["kid"] = key.Kid
+	return token.SignedString(key.private)
+}
 
-	return token, refreshToken, err
+// verificationKey picks the key a token is checked with from its kid. Tokens
+// without one were signed with the HS256 secret.
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		if token.Method != jwt.SigningMethodHS256 || tokenConfig.Secret == "" {
+			return nil, errors.New("the token has no key id")
+		}
+		return []byte(tokenConfig.Secret), nil
+	}
+	if signingKeys == nil {
+		return nil, errors.New("signing keys have not been loaded")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	key, err := signingKeys.verifying(ctx, kid)
+	if err != nil {
+		return nil, err
+	}
+	//never let a token pick another algorithm than its key's
+	if token.Method.Alg() != key.Algorithm {
+		return nil, errors.New("the token algorithm does not match its key")
+	}
+	return key.public, nil
 }
 
+// ValidateToken checks an access token and returns its claims, or why it was
+// rejected.
 func ValidateToken(signedToken string) (claims *SignedDetails, msg string) {
+	return parseToken(signedToken, AccessToken)
+}
+
+// ValidateRefreshToken checks a refresh token and makes sure it belongs to a
+// token family.
+func ValidateRefreshToken(signedToken string) (claims *SignedDetails, msg string) {
+	claims, msg = parseToken(signedToken, RefreshToken)
+	if msg != "" {
+		return nil, msg
+	}
+	if claims.Family == "" {
+		return nil, "the refresh token is invalid"
+	}
+	return claims, msg
+}
+
+// ValidateMFAChallenge checks a challenge token from GenerateMFAChallenge.
+func ValidateMFAChallenge(signedToken string) (claims *SignedDetails, msg string) {
+	return parseToken(signedToken, MFAChallengeToken)
+}
+
+func parseToken(signedToken string, tokenType string) (claims *SignedDetails, msg string) {
 	token, err := jwt.ParseWithClaims(
 		signedToken,
 		&SignedDetails{},
-		func(token *jwt.Token) (interface{}, error) {
-			return []byte(SECRET_KEY), nil
-		},
+		verificationKey,
 	)
 
 	if err != nil {
@@ -74,49 +211,11 @@ func ValidateToken(signedToken string) (claims *SignedDetails, msg string) {
 	claims, ok := token.Claims.(*SignedDetails)
 	if !ok {
 		msg = fmt.Sprintf("the token is invalid")
-		msg = err.Error()
 		return
 	}
 
-	if claims.ExpiresAt < time.Now().Local().Unix() {
-		msg = fmt.Sprintf("token is expired")
-		msg = err.Error()
-		return
+	if claims.Type != tokenType {
+		return nil, fmt.Sprintf("the token type must be %q", tokenType)
 	}
 	return claims, msg
 }
-
-func UpdateAllTokens(signedToken string, signedRefreshToken string, userId string) {
-	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-
-	var updateObj primitive.D
-
-	updateObj = append(updateObj, bson.E{Key: "token", Value: signedToken})
-	updateObj = append(updateObj, bson.E{Key: "refresh_token", Value: signedRefreshToken})
-
-	Updated_at, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-	updateObj = append(updateObj, bson.E{Key: "updated_at", Value: Updated_at})
-
-	upsert := true
-	filter := bson.M{"user_id": userId}
-	opt := options.UpdateOptions{
-		Upsert: &upsert,
-	}
-
-	_, err := userCollection.UpdateOne(
-		ctx,
-		filter,
-		bson.D{
-			{Key: "$set", Value: updateObj},
-		},
-		&opt,
-	)
-
-	defer cancel()
-
-	if err != nil {
-		log.Panic(err)
-		return
-	}
-	return
-}
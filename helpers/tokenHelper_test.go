@@ -0,0 +1,77 @@
+package helper
+
+import (
+	"testing"
+
+	"github.com/genesdemon/golang-jwt-project/config"
+)
+
+func configureHS256(t *testing.T) {
+	t.Helper()
+	cfg := config.Default().JWT
+	cfg.Secret = "test-secret"
+	Configure(cfg)
+}
+
+// Rotation compares refresh tokens, so two pairs issued within the same
+// second must still differ.
+func TestGenerateAllTokensGivesEveryRefreshTokenItsOwnJTI(t *testing.T) {
+	configureHS256(t)
+	family := NewTokenFamily()
+
+	seen := map[string]bool{}
+	jtis := map[string]bool{}
+	for i := 0; i < 5; i++ {
+		_, refreshToken, err := GenerateAllTokens("a@x.io", "A", "alice", "USER", "uid-1", family, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seen[refreshToken] {
+			t.Fatalf("refresh token %d repeats an earlier one", i)
+		}
+		seen[refreshToken] = true
+
+		claims, msg := ValidateRefreshToken(refreshToken)
+		if msg != "" {
+			t.Fatalf("refresh token %d: %s", i, msg)
+		}
+		if claims.Id == "" || jtis[claims.Id] {
+			t.Fatalf("refresh token %d has jti %q, want a new one", i, claims.Id)
+		}
+		jtis[claims.Id] = true
+		if claims.Family != family {
+			t.Fatalf("refresh token %d has family %q, want %q", i, claims.Family, family)
+		}
+	}
+}
+
+func TestTokensAreOnlyAcceptedAsTheirType(t *testing.T) {
+	configureHS256(t)
+	access, refresh, err := GenerateAllTokens("a@x.io", "A", "alice", "USER", "uid-1", NewTokenFamily(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	challenge, err := GenerateMFAChallenge("uid-1", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	validators := map[string]func(string) (*SignedDetails, string){
+		AccessToken:       ValidateToken,
+		RefreshToken:      ValidateRefreshToken,
+		MFAChallengeToken: ValidateMFAChallenge,
+	}
+	tokens := map[string]string{
+		AccessToken:       access,
+		RefreshToken:      refresh,
+		MFAChallengeToken: challenge,
+	}
+	for tokenType, token := range tokens {
+		for validatorType, validate := range validators {
+			_, msg := validate(token)
+			if accepted := msg == ""; accepted != (tokenType == validatorType) {
+				t.Errorf("%s token checked as %s: accepted = %v", tokenType, validatorType, accepted)
+			}
+		}
+	}
+}
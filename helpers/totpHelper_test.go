@@ -0,0 +1,127 @@
+package helper
+
+import (
+	"encoding/base32"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA1 key of the RFC 6238 test vectors.
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestTOTPCodeMatchesRFC6238(t *testing.T) {
+	//the RFC lists 8 digit codes, of which 6 digit codes are the last six
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tt := range tests {
+		step, ok := ValidateTOTP(rfcSecret, tt.want, time.Unix(tt.unix, 0))
+		if !ok {
+			t.Errorf("code %s at %d was refused", tt.want, tt.unix)
+			continue
+		}
+		if step != tt.unix/totpPeriod {
+			t.Errorf("code %s at %d matched step %d, want %d", tt.want, tt.unix, step, tt.unix/totpPeriod)
+		}
+	}
+}
+
+func TestValidateTOTPDrift(t *testing.T) {
+	secret, err := NewTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _ := totpEncoding.DecodeString(secret)
+	now := time.Unix(1700000000, 0)
+	current := now.Unix() / totpPeriod
+
+	tests := []struct {
+		name   string
+		code   string
+		wantOK bool
+	}{
+		{"current step", totpCode(key, current), true},
+		{"one step behind", totpCode(key, current-1), true},
+		{"one step ahead", totpCode(key, current+1), true},
+		{"two steps behind", totpCode(key, current-2), false},
+		{"two steps ahead", totpCode(key, current+2), false},
+		{"too short", totpCode(key, current)[1:], false},
+		{"not a code", "abcdef", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := ValidateTOTP(secret, tt.code, now); ok != tt.wantOK {
+				t.Errorf("ok = %v, want %v", ok, tt.wantOK)
+			}
+		})
+	}
+
+	//secrets typed in lower case still work
+	if _, ok := ValidateTOTP(strings.ToLower(secret), totpCode(key, current), now); !ok {
+		t.Error("lower case secret was refused")
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri, err := url.Parse(TOTPURI("Shive", "a b@x.io", "SECRET"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uri.Scheme != "otpauth" || uri.Host != "totp" || uri.Path != "/Shive:a b@x.io" {
+		t.Errorf("uri = %s", uri)
+	}
+	params := uri.Query()
+	if params.Get("secret") != "SECRET" || params.Get("issuer") != "Shive" || params.Get("digits") != "6" || params.Get("period") != "30" {
+		t.Errorf("params = %v", params)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, hashes, err := NewRecoveryCodes(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != 10 || len(hashes) != 10 {
+		t.Fatalf("got %d codes and %d hashes, want 10 of each", len(codes), len(hashes))
+	}
+	format := regexp.MustCompile(`^[a-z2-7]{4}-[a-z2-7]{4}$`)
+	seen := map[string]bool{}
+	for i, code := range codes {
+		if !format.MatchString(code) {
+			t.Errorf("code %q is not in the xxxx-xxxx form", code)
+		}
+		if seen[code] {
+			t.Errorf("code %q repeats", code)
+		}
+		seen[code] = true
+		if HashRecoveryCode(code) != hashes[i] {
+			t.Errorf("hash of code %d does not match the stored one", i)
+		}
+	}
+
+	code := codes[0]
+	typed := []string{
+		strings.ToUpper(code),
+		strings.Replace(code, "-", "", 1),
+		strings.Replace(code, "-", " ", 1),
+		" " + code,
+	}
+	for _, variant := range typed {
+		if HashRecoveryCode(variant) != hashes[0] {
+			t.Errorf("%q does not hash like %q", variant, code)
+		}
+	}
+	if HashRecoveryCode(codes[1]) == hashes[0] {
+		t.Error("two codes hash the same")
+	}
+}
@@ -0,0 +1,73 @@
+// Package mailer sends the emails the API needs, such as password resets,
+// through a transport chosen in the configuration.
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/genesdemon/golang-jwt-project/config"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Message is a plain text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers messages. Implementations must be safe for concurrent use.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// New returns the Mailer for the configured transport.
+func New(cfg config.MailConfig) (Mailer, error) {
+	switch cfg.Transport {
+	case config.MailLog:
+		return &LogMailer{From: cfg.From}, nil
+	case config.MailFile:
+		if err := os.MkdirAll(cfg.Dir, 0o700); err != nil {
+			return nil, fmt.Errorf("creating mail directory: %w", err)
+		}
+		return &FileMailer{From: cfg.From, Dir: cfg.Dir}, nil
+	}
+	return nil, fmt.Errorf("unknown mail transport %q", cfg.Transport)
+}
+
+// LogMailer writes messages to the standard logger. It is meant for local
+// development, where nobody should receive real email.
+type LogMailer struct {
+	From string
+}
+
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	log.Printf("mail from %s to %s: %s\n%s", m.From, msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// FileMailer writes every message to its own .eml file in Dir, which mail
+// clients can open.
+type FileMailer struct {
+	From string
+	Dir  string
+}
+
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", m.From)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+
+	name := time.Now().Format("20060102T150405") + "-" + primitive.NewObjectID().Hex() + ".eml"
+	return os.WriteFile(filepath.Join(m.Dir, name), []byte(b.String()), 0o600)
+}
@@ -1,38 +1,110 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"net/http"
 	"os"
+	"time"
 
+	"github.com/genesdemon/golang-jwt-project/config"
+	controller "github.com/genesdemon/golang-jwt-project/controllers"
+	"github.com/genesdemon/golang-jwt-project/database"
+	helper "github.com/genesdemon/golang-jwt-project/helpers"
+	"github.com/genesdemon/golang-jwt-project/mailer"
+	"github.com/genesdemon/golang-jwt-project/middleware"
+	"github.com/genesdemon/golang-jwt-project/rbac"
+	"github.com/genesdemon/golang-jwt-project/repository"
+	"github.com/genesdemon/golang-jwt-project/response"
 	routes "github.com/genesdemon/golang-jwt-project/routes"
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
-	port := os.Getenv("PORT") //check for the declared port in the env
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	helper.Configure(cfg.JWT)
+	controller.Configure(cfg)
+
+	var store *repository.Store
+	if cfg.Storage == config.StorageMemory {
+		store = repository.NewMemoryStore()
+	} else {
+		client, err := database.DBinstance(cfg.Mongo.URL)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "connecting to MongoDB:", err)
+			os.Exit(1)
+		}
+		db := database.OpenDatabase(client, cfg.Mongo.Database)
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		err = repository.Migrate(ctx, db)
+		if err != nil {
+			cancel()
+			fmt.Fprintln(os.Stderr, "migrating MongoDB documents:", err)
+			os.Exit(1)
+		}
+		err = repository.EnsureIndexes(ctx, db)
+		cancel()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "creating MongoDB indexes:", err)
+			os.Exit(1)
+		}
+		store = repository.NewMongoStore(db)
+	}
 
-	if port == "" {
-		port = "8000"
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	err = helper.UseSigningKeys(ctx, store.SigningKeys)
+	if err != nil {
+		cancel()
+		fmt.Fprintln(os.Stderr, "loading token signing keys:", err)
+		os.Exit(1)
+	}
+	err = rbac.EnsureDefaultRoles(ctx, store.Roles)
+	if err != nil {
+		cancel()
+		fmt.Fprintln(os.Stderr, "creating default roles:", err)
+		os.Exit(1)
+	}
+	err = controller.BootstrapAdmin(ctx, store.Users, cfg.BootstrapAdmin)
+	cancel()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "bootstrapping the admin account:", err)
+		os.Exit(1)
+	}
+
+	mail, err := mailer.New(cfg.Mail)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "setting up mail:", err)
+		os.Exit(1)
 	}
 
 	router := gin.New()
-	router.Use(gin.Logger())
+	//without trusted proxies ClientIP is the address of the connection, so
+	//clients cannot pick their own address with X-Forwarded-For
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		fmt.Fprintln(os.Stderr, "setting trusted proxies:", err)
+		os.Exit(1)
+	}
+	router.Use(middleware.RequestID(), gin.Logger(), gin.CustomRecovery(func(c *gin.Context, recovered interface{}) {
+		response.Fail(c, response.Internal(fmt.Errorf("panic: %v", recovered)))
+	}))
+	router.NoRoute(func(c *gin.Context) {
+		response.Fail(c, response.NotFound("no route matches "+c.Request.Method+" "+c.Request.URL.Path))
+	})
 
 	//Register our routes
-	routes.AuthRoutes(router)
-	routes.UserRoutes(*router)
-	routes.GenreRoutes(*router)
-	routes.MovieRoutes(*router)
-	routes.ReviewRoutes(*router)
+	routes.Register(router, store, mail, cfg.PublicCatalog)
 
 	router.GET("/api-1", func(c *gin.Context) {
-		c.JSON(200, gin.H{
-			"success": "Access granted for api-1"})
+		response.Success(c, http.StatusOK, "Access granted for api-1")
 	})
 
 	router.GET("/api-2", func(c *gin.Context) {
-		c.JSON(200, gin.H{
-			"success": "Access granted for api-2"})
+		response.Success(c, http.StatusOK, "Access granted for api-2")
 	})
 
-	router.Run(":" + port)
+	router.Run(":" + cfg.Port)
 }
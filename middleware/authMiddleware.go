@@ -1,33 +1,143 @@
 package middleware
 
 import (
-	"fmt"
-	"net/http"
+	"context"
+	"strings"
+	"time"
 
 	helper "github.com/genesdemon/golang-jwt-project/helpers"
+	"github.com/genesdemon/golang-jwt-project/rbac"
+	"github.com/genesdemon/golang-jwt-project/repository"
+	"github.com/genesdemon/golang-jwt-project/response"
 	"github.com/gin-gonic/gin"
 )
 
-func Authenticate() gin.HandlerFunc {
+// authRealm names the protection space in WWW-Authenticate challenges.
+const authRealm = "shive-api"
+
+// APIKeyHeader is an alternative to sending an API key as a bearer token.
+const APIKeyHeader = "X-API-Key"
+
+// Authenticate lets through requests carrying a valid access token or API
+// key, and records who made them and what they may do.
+func Authenticate(users repository.UserRepository, roles repository.RoleRepository, keys repository.APIKeyRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		clientToken := c.Request.Header.Get("token")
-		if clientToken == "" {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("No Authorization header provided")})
-			c.Abort()
+		credential, malformed := credentials(c)
+		if malformed != "" {
+			challenge(c, "invalid_request", malformed)
+			return
+		}
+		if credential == "" {
+			challenge(c, "", "No Authorization header provided")
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if helper.IsAPIKey(credential) {
+			key, err := helper.CheckAPIKey(ctx, keys, credential)
+			switch err {
+			case nil:
+			case helper.ErrInvalidAPIKey, helper.ErrAPIKeyRevoked, helper.ErrAPIKeyExpired:
+				challenge(c, "invalid_token", err.Error())
+				return
+			default:
+				response.Fail(c, err)
+				return
+			}
+			permissions := map[string]bool{}
+			for _, permission := range key.Permissions {
+				permissions[permission] = true
+			}
+			c.Set("api_key_id", key.Key_id)
+			c.Set(helper.PermissionsKey, permissions)
+			c.Next()
 			return
 		}
 
-		claims, err := helper.ValidateToken(clientToken)
+		claims, err := helper.ValidateToken(credential)
 		if err != "" {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err})
-			c.Abort()
+			challenge(c, "invalid_token", err)
 			return
 		}
-		c.Set("email", claims.Email)
-		c.Set("name", claims.Name)
-		c.Set("username", claims.Username)
-		c.Set("uid", claims.Uid)
-		c.Set("user_type", claims.User_type)
+
+		user, sessionErr := helper.CheckTokenVersion(ctx, users, claims.Subject, claims.Version, claims.Family)
+		if sessionErr == helper.ErrSessionRevoked {
+			challenge(c, "invalid_token", sessionErr.Error())
+			return
+		} else if sessionErr != nil {
+			response.Fail(c, sessionErr)
+			return
+		}
+
+		//roles are read from the user, not the token, so changes apply at once
+		roleNames := rbac.RolesOf(user)
+		permissions, permErr := rbac.Permissions(ctx, roles, roleNames)
+		if permErr != nil {
+			response.Fail(c, permErr)
+			return
+		}
+		//so is the profile, which may have changed since the token was issued
+		c.Set("email", stringValue(user.Email))
+		c.Set("name", stringValue(user.Name))
+		c.Set("username", stringValue(user.Username))
+		c.Set("uid", user.User_id)
+		c.Set("email_verified", user.Email_verified)
+		c.Set("user_type", stringValue(user.User_type))
+		c.Set("roles", roleNames)
+		c.Set(helper.PermissionsKey, permissions)
+		c.Next()
+	}
+}
+
+// credentials returns the access token or API key sent with the request. The
+// standard Authorization header wins over X-API-Key and the older token
+// header. A malformed Authorization header is reported instead.
+func credentials(c *gin.Context) (credential string, malformed string) {
+	if header := c.GetHeader("Authorization"); header != "" {
+		scheme, value, _ := strings.Cut(header, " ")
+		value = strings.TrimSpace(value)
+		if !strings.EqualFold(scheme, "Bearer") || value == "" {
+			return "", `the Authorization header must look like "Bearer <token>"`
+		}
+		return value, ""
+	}
+	if key := c.GetHeader(APIKeyHeader); key != "" {
+		return key, ""
+	}
+	return c.GetHeader("token"), ""
+}
+
+// challenge rejects the request with a 401 and a Bearer WWW-Authenticate
+// challenge as described in RFC 6750. code is left out when no credentials
+// were sent at all.
+func challenge(c *gin.Context, code string, message string) {
+	value := `Bearer realm="` + authRealm + `"`
+	if code != "" {
+		value += `, error="` + code + `", error_description="` + strings.ReplaceAll(message, `"`, "'") + `"`
+	}
+	c.Header("WWW-Authenticate", value)
+	response.Fail(c, response.Unauthorized(message))
+}
+
+// RequirePermission lets the request through only when the caller, signed in
+// by Authenticate, holds every one of permissions.
+func RequirePermission(permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, permission := range permissions {
+			if !helper.HasPermission(c, permission) {
+				response.Fail(c, response.Forbidden("missing permission "+permission).With("permission", permission))
+				return
+			}
+		}
 		c.Next()
 	}
 }
+
+func stringValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
@@ -0,0 +1,15 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// Deprecated marks responses of the unversioned paths as deprecated and links
+// to the same path under prefix, which replaces them.
+func Deprecated(prefix string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Deprecation", "true")
+		c.Header("Link", "<"+prefix+c.Request.URL.Path+`>; rel="successor-version"`)
+		c.Next()
+	}
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// APIKey lets a service account call the API without signing in. Only a hash
+// of the secret part is stored; the key itself is shown once, on creation.
+type APIKey struct {
+	Id           primitive.ObjectID `bson:"_id"`
+	Key_id       string             `json:"key_id"`
+	Name         *string            `json:"name" validate:"required,min=3,max=100"`
+	Hash         string             `json:"-"`
+	Permissions  []string           `json:"permissions" validate:"dive,required"`
+	Created_by   string             `json:"created_by"`
+	Created_at   time.Time          `json:"created_at"`
+	Expires_at   *time.Time         `json:"expires_at"`
+	Last_used_at *time.Time         `json:"last_used_at"`
+	Revoked_at   *time.Time         `json:"revoked_at"`
+}
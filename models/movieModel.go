@@ -10,8 +10,32 @@ type Movie struct {
 	Id         primitive.ObjectID `bson:"_id"`
 	Name       *string            `json:"name" validate:"required"`
 	Topic      *string            `json:"topic" validate:"required"`
-	Genre_id   *string            `json:"genre_id" validate:"required"`
+	Genre_ids  []string           `json:"genre_ids" validate:"required,min=1,dive,required"`
+	Genres     []GenreRef         `json:"genres" bson:"-"`
 	Movie_URL  *string            `json:"movie_url" validate:"required"`
+	Ratings    RatingSummary      `json:"ratings"`
 	Created_at time.Time          `json:"created_at"`
 	Updated_at time.Time          `json:"updated_at"`
 }
+
+// RatingSummary aggregates the ratings of every review of a movie. Histogram
+// maps each rating ("1" to "10") to the number of reviews that gave it.
+type RatingSummary struct {
+	Average   float64        `json:"average"`
+	Count     int            `json:"count"`
+	Sum       int            `json:"-"`
+	Histogram map[string]int `json:"histogram" bson:",omitempty"`
+}
+
+// GenreRef is a genre embedded in a movie response. It is filled in from the
+// movie's Genre_ids when the movie is returned and is never stored.
+type GenreRef struct {
+	Id   string  `json:"id"`
+	Name *string `json:"name"`
+}
+
+type MovieSearchResult struct {
+	Movie      Movie             `json:"movie"`
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights"`
+}
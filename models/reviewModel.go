@@ -9,8 +9,22 @@ import (
 type Reviews struct {
 	Id          primitive.ObjectID `bson:"_id"`
 	Movie_id    *string            `json:"movie_id" validate:"required"`
-	Reviewer_id *string            `json:"reviewer_id" validate:"required"`
+	Reviewer_id *string            `json:"reviewer_id"`
 	Review      *string            `json:"review" validate:"required"`
+	Rating      *int               `json:"rating" validate:"required,min=1,max=10"`
+	History     []ReviewRevision   `json:"history" bson:",omitempty"`
 	Created_at  time.Time          `json:"created_at"`
 	Updated_at  time.Time          `json:"updated_at"`
 }
+
+// ReviewRevision is a previous version of a review, kept when it is edited.
+type ReviewRevision struct {
+	Review    *string   `json:"review"`
+	Rating    *int      `json:"rating"`
+	Edited_at time.Time `json:"edited_at"`
+}
+
+type ReviewEdit struct {
+	Review *string `json:"review" validate:"required"`
+	Rating *int    `json:"rating" validate:"required,min=1,max=10"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Role is a named set of permissions that can be assigned to users.
+type Role struct {
+	Id          primitive.ObjectID `bson:"_id"`
+	Name        *string            `json:"name" validate:"required,min=3,max=50"`
+	Permissions []string           `json:"permissions" validate:"required,dive,required"`
+	Created_at  time.Time          `json:"created_at"`
+	Updated_at  time.Time          `json:"updated_at"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SigningKey is a key pair tokens are signed with. A key signs from
+// Activates_at until Retires_at and is published for verification until
+// Expires_at, when the last token it signed has expired.
+type SigningKey struct {
+	Id          primitive.ObjectID `bson:"_id"`
+	Kid         string             `json:"kid"`
+	Algorithm   string             `json:"alg"`
+	Private_key []byte             `json:"-"`
+	// Private_key_encryption names how Private_key is encrypted at rest. It
+	// is empty for keys stored in the clear by older versions.
+	Private_key_encryption string    `json:"-" bson:",omitempty"`
+	Public_key             []byte    `json:"-"`
+	Created_at             time.Time `json:"created_at"`
+	Activates_at           time.Time `json:"activates_at"`
+	Retires_at             time.Time `json:"retires_at"`
+	Expires_at             time.Time `json:"expires_at"`
+}
@@ -7,15 +7,113 @@ import (
 )
 
 type User struct {
-	ID            primitive.ObjectID `bson:"_id"`
-	Name          *string            `json:"name" validate:"required,min=4,max=100"`
-	Username      *string            `json:"username" validate:"required,min=4,max=100"`
-	Password      *string            `json:"Password" validate:"required,min=8"`
-	Email         *string            `json:"email" validate:"email,required"`
-	Token         *string            `json:"token"`
-	User_type     *string            `json:"user_type" validate:"required,eq=ADMIN|eq=USER"`
-	Refresh_token *string            `json:"refresh_token"`
-	Created_at    time.Time          `json:"created_at"`
-	Updated_at    time.Time          `json:"updated_at"`
-	User_id       string             `json:"user_id"`
+	ID                primitive.ObjectID `bson:"_id"`
+	Name              *string            `json:"name" validate:"required,min=4,max=100"`
+	Username          *string            `json:"username" validate:"required,min=4,max=100"`
+	Password          *string            `json:"Password" validate:"required,min=8"`
+	Email             *string            `json:"email" validate:"email,required"`
+	Email_verified    bool               `json:"email_verified"`
+	Mfa_enabled       bool               `json:"mfa_enabled"`
+	Token             *string            `json:"-"`
+	User_type         *string            `json:"user_type" validate:"omitempty,eq=ADMIN|eq=USER"`
+	Roles             []string           `json:"roles"`
+	Sessions          []Session          `json:"-" bson:",omitempty"`
+	Token_version     int                `json:"-"`
+	Created_at        time.Time          `json:"created_at"`
+	Updated_at        time.Time          `json:"updated_at"`
+	Locked_until      *time.Time         `json:"locked_until,omitempty" bson:",omitempty"`
+	User_id           string             `json:"user_id"`
+	Reset_token_hash  *string            `json:"-" bson:",omitempty"`
+	Reset_expires_at  *time.Time         `json:"-" bson:",omitempty"`
+	Verify_token_hash *string            `json:"-" bson:",omitempty"`
+	Verify_expires_at *time.Time         `json:"-" bson:",omitempty"`
+	Failed_logins     int                `json:"-" bson:",omitempty"`
+	Last_failed_login *time.Time         `json:"-" bson:",omitempty"`
+	Totp_secret       *string            `json:"-" bson:",omitempty"`
+	Totp_last_step    int64              `json:"-" bson:",omitempty"`
+	Recovery_codes    []string           `json:"-" bson:",omitempty"`
+}
+
+// Session is the sign in of one device. Its refresh token is replaced on every
+// refresh, and each device has its own family, so devices rotate their tokens
+// and are revoked independently.
+type Session struct {
+	Family        string    `json:"-"`
+	Refresh_token string    `json:"-"`
+	Created_at    time.Time `json:"-"`
+}
+
+// HasSession reports whether the user is still signed in with the refresh
+// token family.
+func (u *User) HasSession(family string) bool {
+	for _, session := range u.Sessions {
+		if session.Family == family {
+			return true
+		}
+	}
+	return false
+}
+
+// UserUpdate holds the profile fields a user can change. Fields left out stay
+// as they are.
+type UserUpdate struct {
+	Name     *string `json:"name" validate:"omitempty,min=4,max=100"`
+	Username *string `json:"username" validate:"omitempty,min=4,max=100"`
+	Email    *string `json:"email" validate:"omitempty,email"`
+}
+
+// PrivateUser is an account as its owner and admins see it. It holds none of
+// the secrets of User, so it is safe to return.
+type PrivateUser struct {
+	ID             primitive.ObjectID `bson:"_id" json:"-"`
+	User_id        string             `json:"user_id"`
+	Name           *string            `json:"name"`
+	Username       *string            `json:"username"`
+	Email          *string            `json:"email"`
+	Email_verified bool               `json:"email_verified"`
+	Mfa_enabled    bool               `json:"mfa_enabled"`
+	User_type      *string            `json:"user_type"`
+	Roles          []string           `json:"roles"`
+	Locked_until   *time.Time         `json:"locked_until,omitempty" bson:",omitempty"`
+	Created_at     time.Time          `json:"created_at"`
+	Updated_at     time.Time          `json:"updated_at"`
+}
+
+// PublicUser is what any signed in user can see of another account.
+type PublicUser struct {
+	ID       primitive.ObjectID `bson:"_id" json:"-"`
+	User_id  string             `json:"user_id"`
+	Name     *string            `json:"name"`
+	Username *string            `json:"username"`
+}
+
+func NewPrivateUser(user *User) *PrivateUser {
+	return &PrivateUser{
+		ID:             user.ID,
+		User_id:        user.User_id,
+		Name:           user.Name,
+		Username:       user.Username,
+		Email:          user.Email,
+		Email_verified: user.Email_verified,
+		Mfa_enabled:    user.Mfa_enabled,
+		User_type:      user.User_type,
+		Roles:          user.Roles,
+		Locked_until:   user.Locked_until,
+		Created_at:     user.Created_at,
+		Updated_at:     user.Updated_at,
+	}
+}
+
+func NewPublicUser(user *User) *PublicUser {
+	return &PublicUser{ID: user.ID, User_id: user.User_id, Name: user.Name, Username: user.Username}
+}
+
+// TokenResponse is the only response that carries tokens. Signing in and
+// refreshing answer with it.
+type TokenResponse struct {
+	Token         string       `json:"token"`
+	Refresh_token string       `json:"refresh_token"`
+	Token_type    string       `json:"token_type"`
+	Expires_in    int          `json:"expires_in"`
+	User          *PrivateUser `json:"user"`
 }
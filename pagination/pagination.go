@@ -0,0 +1,171 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"errors"
+
+	"github.com/genesdemon/golang-jwt-project/query"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Limits applied to every list endpoint.
+const (
+	DefaultLimit = 10
+	MaxLimit     = 100
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Key is the position of a document in a sorted list: its values for the sort
+// keys, with the ID breaking ties.
+type Key struct {
+	Values []interface{}
+	Id     primitive.ObjectID
+}
+
+// KeyOf reads the position of a stored document in the given order.
+func KeyOf(doc bson.M, sort query.Sort) Key {
+	key := Key{Values: make([]interface{}, len(sort))}
+	for i, f := range sort {
+		key.Values[i] = query.Lookup(doc, f.Key)
+	}
+	key.Id, _ = doc["_id"].(primitive.ObjectID)
+	return key
+}
+
+// Compare orders two keys in the given order. Missing values sort first, as
+// they do in Mongo.
+func Compare(a Key, b Key, sort query.Sort) int {
+	for i, f := range sort {
+		cmp, ok := query.Compare(a.Values[i], b.Values[i])
+		if !ok {
+			cmp = compareMissing(a.Values[i], b.Values[i])
+		}
+		if f.Desc {
+			cmp = -cmp
+		}
+		if cmp != 0 {
+			return cmp
+		}
+	}
+	cmp, _ := query.Compare(a.Id, b.Id)
+	return cmp
+}
+
+func compareMissing(a, b interface{}) int {
+	switch {
+	case a == nil && b != nil:
+		return -1
+	case a != nil && b == nil:
+		return 1
+	}
+	return 0
+}
+
+// Cursor points between two documents. A forward cursor asks for the
+// documents after Key, a backward one for those before it. It remembers the
+// sort it was made for, as it means nothing in another order.
+type Cursor struct {
+	Key
+	Backward bool
+	Sort     string
+}
+
+type encodedCursor struct {
+	Values   bson.A             `bson:"v"`
+	Id       primitive.ObjectID `bson:"i"`
+	Backward bool               `bson:"b,omitempty"`
+	Sort     string             `bson:"s"`
+}
+
+// Encode turns the cursor into the opaque string handed to clients.
+func (c Cursor) Encode() string {
+	data, _ := bson.Marshal(encodedCursor{Values: c.Values, Id: c.Id, Backward: c.Backward, Sort: c.Sort})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Decode parses a cursor produced by Encode for a list in the given order.
+func Decode(value string, sort query.Sort) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var encoded encodedCursor
+	if err := bson.Unmarshal(data, &encoded); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if encoded.Sort != sort.String() || len(encoded.Values) != len(sort) {
+		return nil, ErrInvalidCursor
+	}
+	return &Cursor{Key: Key{Values: encoded.Values, Id: encoded.Id}, Backward: encoded.Backward, Sort: encoded.Sort}, nil
+}
+
+// Request describes the page a client asked for. A nil Cursor asks for the
+// first page.
+type Request struct {
+	Limit     int
+	Sort      query.Sort
+	Cursor    *Cursor
+	WithTotal bool
+}
+
+// Page is one page of a list. Next and Prev are empty when there is nothing
+// further in that direction; Total is only set when it was requested.
+type Page[T any] struct {
+	Items []T
+	Next  string
+	Prev  string
+	Total *int64
+}
+
+// Build turns the documents fetched for req into a page. fetched must hold up
+// to req.Limit+1 documents ordered away from the cursor, so that the extra
+// one tells whether another page follows.
+func Build[T any](req Request, fetched []T) (*Page[T], error) {
+	more := len(fetched) > req.Limit
+	if more {
+		fetched = fetched[:req.Limit]
+	}
+	backward := req.Cursor != nil && req.Cursor.Backward
+	if backward {
+		for i, j := 0, len(fetched)-1; i < j; i, j = i+1, j-1 {
+			fetched[i], fetched[j] = fetched[j], fetched[i]
+		}
+	}
+
+	page := &Page[T]{Items: fetched}
+	if len(fetched) == 0 {
+		return page, nil
+	}
+	first, err := req.cursorAt(&fetched[0], true)
+	if err != nil {
+		return nil, err
+	}
+	last, err := req.cursorAt(&fetched[len(fetched)-1], false)
+	if err != nil {
+		return nil, err
+	}
+	if backward {
+		page.Next = last
+		if more {
+			page.Prev = first
+		}
+	} else {
+		if more {
+			page.Next = last
+		}
+		if req.Cursor != nil {
+			page.Prev = first
+		}
+	}
+	return page, nil
+}
+
+func (req Request) cursorAt(item interface{}, backward bool) (string, error) {
+	doc, err := query.Document(item)
+	if err != nil {
+		return "", err
+	}
+	return Cursor{Key: KeyOf(doc, req.Sort), Backward: backward, Sort: req.Sort.String()}.Encode(), nil
+}
@@ -0,0 +1,151 @@
+package pagination
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/genesdemon/golang-jwt-project/query"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var byRating = query.Sort{{Key: "rating", Desc: true}, {Key: "name"}}
+
+func TestCursorRoundTrip(t *testing.T) {
+	id := primitive.NewObjectID()
+	cursor := Cursor{
+		Key:      Key{Values: []interface{}{8.5, "Alien"}, Id: id},
+		Backward: true,
+		Sort:     byRating.String(),
+	}
+	decoded, err := Decode(cursor.Encode(), byRating)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Id != id || !decoded.Backward || decoded.Sort != cursor.Sort {
+		t.Errorf("decoded %+v, want %+v", decoded, cursor)
+	}
+	if !reflect.DeepEqual([]interface{}(decoded.Values), cursor.Values) {
+		t.Errorf("decoded values %#v, want %#v", decoded.Values, cursor.Values)
+	}
+}
+
+func TestDecodeRejects(t *testing.T) {
+	valid := Cursor{Key: Key{Values: []interface{}{8.5, "Alien"}}, Sort: byRating.String()}.Encode()
+	tests := []struct {
+		name  string
+		value string
+		sort  query.Sort
+	}{
+		{"not base64", "%%%", byRating},
+		{"not bson", "bm90IGJzb24", byRating},
+		{"another sort", valid, query.Sort{{Key: "name"}}},
+		{"another direction", valid, query.Sort{{Key: "rating"}, {Key: "name"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Decode(tt.value, tt.sort); err != ErrInvalidCursor {
+				t.Errorf("err = %v, want ErrInvalidCursor", err)
+			}
+		})
+	}
+}
+
+func TestCompare(t *testing.T) {
+	low, high := primitive.NewObjectIDFromTimestamp(time.Unix(0, 0)), primitive.NewObjectID()
+	tests := []struct {
+		name string
+		a, b Key
+		want int
+	}{
+		{"descending first key", Key{Values: []interface{}{9.0, "A"}}, Key{Values: []interface{}{8.0, "A"}}, -1},
+		{"ascending second key", Key{Values: []interface{}{8.0, "A"}}, Key{Values: []interface{}{8.0, "B"}}, -1},
+		{"id breaks ties", Key{Values: []interface{}{8.0, "A"}, Id: low}, Key{Values: []interface{}{8.0, "A"}, Id: high}, -1},
+		{"same position", Key{Values: []interface{}{8.0, "A"}, Id: low}, Key{Values: []interface{}{8.0, "A"}, Id: low}, 0},
+		//missing values sort first, so last in a descending order
+		{"missing value", Key{Values: []interface{}{nil, "A"}}, Key{Values: []interface{}{1.0, "A"}}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Compare(tt.a, tt.b, byRating); got != tt.want {
+				t.Errorf("Compare = %d, want %d", got, tt.want)
+			}
+			if got := Compare(tt.b, tt.a, byRating); got != -tt.want {
+				t.Errorf("reversed Compare = %d, want %d", got, -tt.want)
+			}
+		})
+	}
+}
+
+type item struct {
+	Id   primitive.ObjectID `bson:"_id"`
+	Name string             `bson:"name"`
+}
+
+func TestBuild(t *testing.T) {
+	byName := query.Sort{{Key: "name"}}
+	items := []item{
+		{Id: primitive.NewObjectID(), Name: "a"},
+		{Id: primitive.NewObjectID(), Name: "b"},
+		{Id: primitive.NewObjectID(), Name: "c"},
+	}
+	cursor := &Cursor{Sort: byName.String()}
+	backward := &Cursor{Backward: true, Sort: byName.String()}
+
+	tests := []struct {
+		name      string
+		req       Request
+		fetched   []item
+		wantNames []string
+		wantNext  bool
+		wantPrev  bool
+	}{
+		{"first page with more", Request{Limit: 2, Sort: byName}, items, []string{"a", "b"}, true, false},
+		{"only page", Request{Limit: 3, Sort: byName}, items, []string{"a", "b", "c"}, false, false},
+		{"last page after a cursor", Request{Limit: 3, Sort: byName, Cursor: cursor}, items[1:], []string{"b", "c"}, false, true},
+		//backward pages are fetched nearest the cursor first
+		{"backward with more", Request{Limit: 2, Sort: byName, Cursor: backward}, []item{items[2], items[1], items[0]}, []string{"b", "c"}, true, true},
+		{"backward to the start", Request{Limit: 2, Sort: byName, Cursor: backward}, []item{items[1], items[0]}, []string{"a", "b"}, true, false},
+		{"empty", Request{Limit: 2, Sort: byName, Cursor: cursor}, nil, nil, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fetched := append([]item{}, tt.fetched...)
+			page, err := Build(tt.req, fetched)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, it := range page.Items {
+				names = append(names, it.Name)
+			}
+			if !reflect.DeepEqual(names, tt.wantNames) {
+				t.Errorf("items = %v, want %v", names, tt.wantNames)
+			}
+			if (page.Next != "") != tt.wantNext || (page.Prev != "") != tt.wantPrev {
+				t.Errorf("next = %q, prev = %q, want next %v, prev %v", page.Next, page.Prev, tt.wantNext, tt.wantPrev)
+			}
+		})
+	}
+}
+
+func TestBuildCursorsPointAtPageEdges(t *testing.T) {
+	byName := query.Sort{{Key: "name"}}
+	items := []item{{Id: primitive.NewObjectID(), Name: "a"}, {Id: primitive.NewObjectID(), Name: "b"}}
+	page, err := Build(Request{Limit: 2, Sort: byName, Cursor: &Cursor{Sort: byName.String()}}, items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev, err := Decode(page.Prev, byName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !prev.Backward || prev.Id != items[0].Id || !reflect.DeepEqual([]interface{}(prev.Values), []interface{}{"a"}) {
+		t.Errorf("prev cursor = %+v, want before %q", prev, "a")
+	}
+	doc := bson.M{"_id": items[0].Id, "name": "a"}
+	if Compare(prev.Key, KeyOf(doc, byName), byName) != 0 {
+		t.Error("prev cursor is not at the first item")
+	}
+}
@@ -0,0 +1,162 @@
+package query
+
+import (
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Condition compares one stored key with a value using a Mongo comparison
+// operator ($eq, $ne, $gt, $gte, $lt, $lte or $in).
+type Condition struct {
+	Key   string
+	Op    string
+	Value interface{}
+}
+
+// Filter is a list of conditions that must all hold.
+type Filter []Condition
+
+// Mongo returns the filter as a Mongo query document.
+func (f Filter) Mongo() bson.M {
+	if len(f) == 0 {
+		return bson.M{}
+	}
+	all := bson.A{}
+	for _, c := range f {
+		all = append(all, bson.M{c.Key: bson.M{c.Op: c.Value}})
+	}
+	return bson.M{"$and": all}
+}
+
+// Match evaluates the filter against a document the way Mongo would: a
+// condition on an array holds when it holds for any element, and a missing
+// key only satisfies $ne.
+func (f Filter) Match(doc bson.M) bool {
+	for _, c := range f {
+		if !c.match(Lookup(doc, c.Key)) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c Condition) match(value interface{}) bool {
+	if c.Op == "$ne" {
+		return !Condition{Key: c.Key, Op: "$eq", Value: c.Value}.match(value)
+	}
+	if items, ok := value.(bson.A); ok {
+		for _, item := range items {
+			if c.match(item) {
+				return true
+			}
+		}
+		return false
+	}
+	if value == nil {
+		return false
+	}
+	if c.Op == "$in" {
+		for _, want := range c.Value.([]interface{}) {
+			if cmp, ok := Compare(value, want); ok && cmp == 0 {
+				return true
+			}
+		}
+		return false
+	}
+
+	cmp, ok := Compare(value, c.Value)
+	if !ok {
+		return false
+	}
+	switch c.Op {
+	case "$eq":
+		return cmp == 0
+	case "$gt":
+		return cmp > 0
+	case "$gte":
+		return cmp >= 0
+	case "$lt":
+		return cmp < 0
+	case "$lte":
+		return cmp <= 0
+	}
+	return false
+}
+
+// Document converts a model to the document Mongo would store, so in-memory
+// filtering and sorting see the same keys and types.
+func Document(v interface{}) (bson.M, error) {
+	data, err := bson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var doc bson.M
+	err = bson.Unmarshal(data, &doc)
+	return doc, err
+}
+
+// Lookup returns the value at a dotted key such as "ratings.average", or nil.
+func Lookup(doc bson.M, key string) interface{} {
+	var value interface{} = doc
+	for _, part := range strings.Split(key, ".") {
+		inner, ok := value.(bson.M)
+		if !ok {
+			return nil
+		}
+		value = inner[part]
+	}
+	return value
+}
+
+// Compare orders two values of the same kind. ok is false when they cannot be
+// compared, as Mongo never matches a comparison across types.
+func Compare(a, b interface{}) (cmp int, ok bool) {
+	if x, isNumber := number(a); isNumber {
+		y, isNumber := number(b)
+		if !isNumber {
+			return 0, false
+		}
+		return order(x < y, x > y), true
+	}
+	switch x := a.(type) {
+	case string:
+		y, isString := b.(string)
+		return order(x < y, x > y), isString
+	case primitive.DateTime:
+		y, isTime := b.(primitive.DateTime)
+		return order(x < y, x > y), isTime
+	case primitive.ObjectID:
+		y, isID := b.(primitive.ObjectID)
+		return order(x.Hex() < y.Hex(), x.Hex() > y.Hex()), isID
+	case bool:
+		y, isBool := b.(bool)
+		return order(!x && y, x && !y), isBool
+	}
+	return 0, false
+}
+
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+func order(less bool, greater bool) int {
+	if less {
+		return -1
+	}
+	if greater {
+		return 1
+	}
+	return 0
+}
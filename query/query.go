@@ -0,0 +1,194 @@
+package query
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Kind is the type of a listable field, used to parse filter values.
+type Kind int
+
+const (
+	String Kind = iota
+	Number
+	Time
+)
+
+// Field is one field a list endpoint lets clients sort or filter on. Name is
+// what clients write in the query, Key the stored (bson) key.
+type Field struct {
+	Name       string
+	Key        string
+	Kind       Kind
+	Sortable   bool
+	Filterable bool
+}
+
+// Schema is the whitelist of fields of one list endpoint.
+type Schema []Field
+
+func (s Schema) field(name string) (Field, bool) {
+	for _, f := range s {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return Field{}, false
+}
+
+// SortField orders a list by one stored key.
+type SortField struct {
+	Key  string
+	Desc bool
+}
+
+// Sort is the order of a list, most significant key first. Lists are always
+// finally ordered by _id so that every document has a distinct position.
+type Sort []SortField
+
+// DefaultSort lists oldest documents first.
+var DefaultSort = Sort{{Key: "created_at"}}
+
+func (s Sort) String() string {
+	keys := make([]string, len(s))
+	for i, f := range s {
+		keys[i] = f.Key
+		if f.Desc {
+			keys[i] = "-" + f.Key
+		}
+	}
+	return strings.Join(keys, ",")
+}
+
+// ValidationError lists every problem found in the query string.
+type ValidationError []string
+
+func (e ValidationError) Error() string {
+	return "invalid query: " + strings.Join(e, "; ")
+}
+
+var filterParam = regexp.MustCompile(`^filter\[([^\]]+)\](?:\[([a-z]+)\])?$`)
+
+var operators = map[string]string{
+	"eq": "$eq", "ne": "$ne", "gt": "$gt", "gte": "$gte", "lt": "$lt", "lte": "$lte",
+}
+
+// Parse reads the sort, filter[field], filter[field][op] and <name>_after /
+// <name>_before (for a <name>_at time field) parameters. Parameters it does
+// not own, such as limit or cursor, are ignored; unknown or non-whitelisted
+// fields and malformed values are reported together.
+func Parse(values url.Values, schema Schema) (Filter, Sort, error) {
+	var problems ValidationError
+	filter := Filter{}
+	order := DefaultSort
+
+	if spec := values.Get("sort"); spec != "" {
+		order = Sort{}
+		for _, name := range strings.Split(spec, ",") {
+			name = strings.TrimSpace(name)
+			desc := strings.HasPrefix(name, "-")
+			name = strings.TrimPrefix(name, "-")
+			f, ok := schema.field(name)
+			if !ok || !f.Sortable {
+				problems = append(problems, "sort: unknown field "+strconv.Quote(name))
+				continue
+			}
+			order = append(order, SortField{Key: f.Key, Desc: desc})
+		}
+	}
+
+	params := make([]string, 0, len(values))
+	for param := range values {
+		params = append(params, param)
+	}
+	sort.Strings(params)
+
+	for _, param := range params {
+		given := values[param]
+		var name, op string
+		if match := filterParam.FindStringSubmatch(param); match != nil {
+			name, op = match[1], match[2]
+			if op == "" {
+				op = "eq"
+			}
+		} else if strings.HasSuffix(param, "_after") {
+			name, op = strings.TrimSuffix(param, "_after")+"_at", "gt"
+		} else if strings.HasSuffix(param, "_before") {
+			name, op = strings.TrimSuffix(param, "_before")+"_at", "lt"
+		} else {
+			continue
+		}
+
+		f, ok := schema.field(name)
+		if !ok || !f.Filterable {
+			problems = append(problems, param+": unknown field "+strconv.Quote(name))
+			continue
+		}
+		mongoOp, ok := operators[op]
+		if !ok {
+			problems = append(problems, param+": unknown operator "+strconv.Quote(op))
+			continue
+		}
+		for _, raw := range given {
+			condition, err := parseCondition(f, mongoOp, raw)
+			if err != nil {
+				problems = append(problems, param+": "+err.Error())
+				continue
+			}
+			filter = append(filter, condition)
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, nil, problems
+	}
+	return filter, order, nil
+}
+
+// parseCondition converts raw to the field's kind. A comma separated value
+// given to eq matches any of the listed values.
+func parseCondition(f Field, op string, raw string) (Condition, error) {
+	parts := []string{raw}
+	if op == "$eq" && strings.Contains(raw, ",") {
+		parts = strings.Split(raw, ",")
+		op = "$in"
+	}
+	parsed := make([]interface{}, 0, len(parts))
+	for _, part := range parts {
+		value, err := parseValue(f.Kind, strings.TrimSpace(part))
+		if err != nil {
+			return Condition{}, err
+		}
+		parsed = append(parsed, value)
+	}
+	if op == "$in" {
+		return Condition{Key: f.Key, Op: op, Value: parsed}, nil
+	}
+	return Condition{Key: f.Key, Op: op, Value: parsed[0]}, nil
+}
+
+func parseValue(kind Kind, raw string) (interface{}, error) {
+	switch kind {
+	case Number:
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", raw)
+		}
+		return n, nil
+	case Time:
+		for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+			if t, err := time.Parse(layout, raw); err == nil {
+				return primitive.NewDateTimeFromTime(t), nil
+			}
+		}
+		return nil, fmt.Errorf("%q is not an RFC 3339 time or a date", raw)
+	}
+	return raw, nil
+}
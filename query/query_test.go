@@ -0,0 +1,144 @@
+package query
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var testSchema = Schema{
+	{Name: "name", Key: "name", Kind: String, Sortable: true, Filterable: true},
+	{Name: "rating", Key: "ratings.average", Kind: Number, Sortable: true, Filterable: true},
+	{Name: "genre_id", Key: "genre_ids", Kind: String, Filterable: true},
+	{Name: "created_at", Key: "created_at", Kind: Time, Sortable: true, Filterable: true},
+	{Name: "secret", Key: "secret", Kind: String},
+}
+
+func TestParse(t *testing.T) {
+	day := primitive.NewDateTimeFromTime(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC))
+	tests := []struct {
+		name       string
+		query      string
+		wantFilter Filter
+		wantSort   Sort
+		wantErr    bool
+	}{
+		{
+			name:     "defaults",
+			query:    "limit=5&cursor=abc",
+			wantSort: DefaultSort,
+		},
+		{
+			name:     "sort on several fields",
+			query:    "sort=-rating,name",
+			wantSort: Sort{{Key: "ratings.average", Desc: true}, {Key: "name"}},
+		},
+		{
+			name:       "filter without operator is eq",
+			query:      "filter[name]=Alien",
+			wantFilter: Filter{{Key: "name", Op: "$eq", Value: "Alien"}},
+			wantSort:   DefaultSort,
+		},
+		{
+			name:       "comma separated eq is in",
+			query:      "filter[genre_id]=a,b",
+			wantFilter: Filter{{Key: "genre_ids", Op: "$in", Value: []interface{}{"a", "b"}}},
+			wantSort:   DefaultSort,
+		},
+		{
+			name:       "numbers are parsed",
+			query:      "filter[rating][gte]=7.5",
+			wantFilter: Filter{{Key: "ratings.average", Op: "$gte", Value: 7.5}},
+			wantSort:   DefaultSort,
+		},
+		{
+			name:       "after and before filter time fields",
+			query:      "created_after=2024-05-01",
+			wantFilter: Filter{{Key: "created_at", Op: "$gt", Value: day}},
+			wantSort:   DefaultSort,
+		},
+		{name: "unknown sort field", query: "sort=budget", wantErr: true},
+		{name: "field that is not sortable", query: "sort=genre_id", wantErr: true},
+		{name: "field that is not filterable", query: "filter[secret]=x", wantErr: true},
+		{name: "unknown operator", query: "filter[rating][like]=7", wantErr: true},
+		{name: "not a number", query: "filter[rating]=high", wantErr: true},
+		{name: "not a time", query: "created_before=yesterday", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			filter, sort, err := Parse(values, testSchema)
+			if tt.wantErr {
+				if _, ok := err.(ValidationError); !ok {
+					t.Fatalf("err = %v, want a ValidationError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantFilter == nil {
+				tt.wantFilter = Filter{}
+			}
+			if !reflect.DeepEqual(filter, tt.wantFilter) {
+				t.Errorf("filter = %#v, want %#v", filter, tt.wantFilter)
+			}
+			if !reflect.DeepEqual(sort, tt.wantSort) {
+				t.Errorf("sort = %#v, want %#v", sort, tt.wantSort)
+			}
+		})
+	}
+}
+
+func TestParseReportsEveryProblem(t *testing.T) {
+	values, _ := url.ParseQuery("sort=budget&filter[rating]=high&filter[secret]=x")
+	_, _, err := Parse(values, testSchema)
+	problems, ok := err.(ValidationError)
+	if !ok || len(problems) != 3 {
+		t.Fatalf("err = %v, want three problems", err)
+	}
+}
+
+func TestFilterMatch(t *testing.T) {
+	doc := bson.M{
+		"name":      "Alien",
+		"genre_ids": bson.A{"horror", "scifi"},
+		"ratings":   bson.M{"average": 8.5, "count": int32(2)},
+	}
+	tests := []struct {
+		name   string
+		filter Filter
+		want   bool
+	}{
+		{"empty filter", Filter{}, true},
+		{"eq", Filter{{Key: "name", Op: "$eq", Value: "Alien"}}, true},
+		{"ne", Filter{{Key: "name", Op: "$ne", Value: "Alien"}}, false},
+		{"nested number", Filter{{Key: "ratings.average", Op: "$gte", Value: 8.0}}, true},
+		{"int32 against float", Filter{{Key: "ratings.count", Op: "$eq", Value: 2.0}}, true},
+		{"any element of an array", Filter{{Key: "genre_ids", Op: "$eq", Value: "scifi"}}, true},
+		{"no element of an array", Filter{{Key: "genre_ids", Op: "$eq", Value: "drama"}}, false},
+		{"ne on an array", Filter{{Key: "genre_ids", Op: "$ne", Value: "horror"}}, false},
+		{"in", Filter{{Key: "genre_ids", Op: "$in", Value: []interface{}{"drama", "horror"}}}, true},
+		{"missing key", Filter{{Key: "topic", Op: "$eq", Value: "space"}}, false},
+		{"missing key with ne", Filter{{Key: "topic", Op: "$ne", Value: "space"}}, true},
+		{"across types", Filter{{Key: "name", Op: "$gt", Value: 1.0}}, false},
+		{"every condition must hold", Filter{
+			{Key: "name", Op: "$eq", Value: "Alien"},
+			{Key: "ratings.average", Op: "$lt", Value: 5.0},
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Match(doc); got != tt.want {
+				t.Errorf("Match = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package rbac
+
+import (
+	"context"
+	"time"
+
+	"github.com/genesdemon/golang-jwt-project/models"
+	"github.com/genesdemon/golang-jwt-project/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Permissions a role can grant.
+const (
+	MovieWrite     = "movie:write"
+	GenreWrite     = "genre:write"
+	ReviewWrite    = "review:write"
+	ReviewModerate = "review:moderate"
+	UserRead       = "user:read"
+	UserWrite      = "user:write"
+	RoleManage     = "role:manage"
+)
+
+// All lists every known permission.
+var All = []string{MovieWrite, GenreWrite, ReviewWrite, ReviewModerate, UserRead, UserWrite, RoleManage}
+
+// Built-in roles. They match the old user types so existing accounts keep
+// their access.
+const (
+	Admin = "ADMIN"
+	User  = "USER"
+)
+
+// DefaultRoles are created on start when missing. Changing their permissions
+// later through the API is kept.
+var DefaultRoles = map[string][]string{
+	Admin: {MovieWrite, GenreWrite, ReviewModerate, UserRead, UserWrite, RoleManage},
+	User:  {ReviewWrite},
+}
+
+// Known reports whether permission is one of All.
+func Known(permission string) bool {
+	for _, p := range All {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// RolesOf returns the names of the roles a user holds. The user type counts as
+// a role, so accounts created before roles existed keep working.
+func RolesOf(user *models.User) []string {
+	names := append([]string{}, user.Roles...)
+	if user.User_type != nil {
+		names = append(names, *user.User_type)
+	}
+	return names
+}
+
+// Permissions returns the set of permissions granted by the named roles.
+// Names of roles that do not exist grant nothing.
+func Permissions(ctx context.Context, roles repository.RoleRepository, names []string) (map[string]bool, error) {
+	found, err := roles.FindByNames(ctx, names)
+	if err != nil {
+		return nil, err
+	}
+	granted := map[string]bool{}
+	for _, role := range found {
+		for _, permission := range role.Permissions {
+			granted[permission] = true
+		}
+	}
+	return granted, nil
+}
+
+// EnsureDefaultRoles creates whichever of DefaultRoles does not exist yet.
+func EnsureDefaultRoles(ctx context.Context, roles repository.RoleRepository) error {
+	for name, permissions := range DefaultRoles {
+		_, err := roles.FindByName(ctx, name)
+		if err == nil {
+			continue
+		}
+		if err != repository.ErrNotFound {
+			return err
+		}
+		roleName := name
+		now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		role := models.Role{
+			Id:          primitive.NewObjectID(),
+			Name:        &roleName,
+			Permissions: permissions,
+			Created_at:  now,
+			Updated_at:  now,
+		}
+		if err := roles.Create(ctx, &role); err != nil && err != repository.ErrDuplicate {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package repository
+
+import "github.com/genesdemon/golang-jwt-project/query"
+
+// The fields each list endpoint may be sorted and filtered on. Anything else
+// in a sort or filter parameter is rejected.
+var (
+	UserFields = query.Schema{
+		{Name: "name", Key: "name", Kind: query.String, Sortable: true, Filterable: true},
+		{Name: "username", Key: "username", Kind: query.String, Sortable: true, Filterable: true},
+		{Name: "email", Key: "email", Kind: query.String, Sortable: true, Filterable: true},
+		{Name: "user_type", Key: "user_type", Kind: query.String, Sortable: true, Filterable: true},
+		{Name: "user_id", Key: "user_id", Kind: query.String, Filterable: true},
+		{Name: "role", Key: "roles", Kind: query.String, Filterable: true},
+		{Name: "created_at", Key: "created_at", Kind: query.Time, Sortable: true, Filterable: true},
+		{Name: "updated_at", Key: "updated_at", Kind: query.Time, Sortable: true, Filterable: true},
+	}
+
+	RoleFields = query.Schema{
+		{Name: "name", Key: "name", Kind: query.String, Sortable: true, Filterable: true},
+		{Name: "permissions", Key: "permissions", Kind: query.String, Filterable: true},
+		{Name: "created_at", Key: "created_at", Kind: query.Time, Sortable: true, Filterable: true},
+		{Name: "updated_at", Key: "updated_at", Kind: query.Time, Sortable: true, Filterable: true},
+	}
+
+	APIKeyFields = query.Schema{
+		{Name: "name", Key: "name", Kind: query.String, Sortable: true, Filterable: true},
+		{Name: "key_id", Key: "key_id", Kind: query.String, Filterable: true},
+		{Name: "created_by", Key: "created_by", Kind: query.String, Filterable: true},
+		{Name: "permissions", Key: "permissions", Kind: query.String, Filterable: true},
+		{Name: "created_at", Key: "created_at", Kind: query.Time, Sortable: true, Filterable: true},
+		{Name: "expires_at", Key: "expires_at", Kind: query.Time, Sortable: true, Filterable: true},
+		{Name: "last_used_at", Key: "last_used_at", Kind: query.Time, Sortable: true, Filterable: true},
+	}
+
+	GenreFields = query.Schema{
+		{Name: "name", Key: "name", Kind: query.String, Sortable: true, Filterable: true},
+		{Name: "created_at", Key: "created_at", Kind: query.Time, Sortable: true, Filterable: true},
+		{Name: "updated_at", Key: "updated_at", Kind: query.Time, Sortable: true, Filterable: true},
+	}
+
+	MovieFields = query.Schema{
+		{Name: "name", Key: "name", Kind: query.String, Sortable: true, Filterable: true},
+		{Name: "topic", Key: "topic", Kind: query.String, Sortable: true, Filterable: true},
+		{Name: "genre_id", Key: "genre_ids", Kind: query.String, Filterable: true},
+		{Name: "genre_ids", Key: "genre_ids", Kind: query.String, Filterable: true},
+		{Name: "rating", Key: "ratings.average", Kind: query.Number, Sortable: true, Filterable: true},
+		{Name: "rating_count", Key: "ratings.count", Kind: query.Number, Sortable: true, Filterable: true},
+		{Name: "created_at", Key: "created_at", Kind: query.Time, Sortable: true, Filterable: true},
+		{Name: "updated_at", Key: "updated_at", Kind: query.Time, Sortable: true, Filterable: true},
+	}
+
+	ReviewFields = query.Schema{
+		{Name: "rating", Key: "rating", Kind: query.Number, Sortable: true, Filterable: true},
+		{Name: "movie_id", Key: "movie_id", Kind: query.String, Filterable: true},
+		{Name: "reviewer_id", Key: "reviewer_id", Kind: query.String, Filterable: true},
+		{Name: "created_at", Key: "created_at", Kind: query.Time, Sortable: true, Filterable: true},
+		{Name: "updated_at", Key: "updated_at", Kind: query.Time, Sortable: true, Filterable: true},
+	}
+)
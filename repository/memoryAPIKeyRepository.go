@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/genesdemon/golang-jwt-project/models"
+	"github.com/genesdemon/golang-jwt-project/pagination"
+	"github.com/genesdemon/golang-jwt-project/query"
+)
+
+type memoryAPIKeyRepository struct {
+	table memoryTable[models.APIKey]
+}
+
+func apiKeyByKeyID(keyId string) func(*models.APIKey) bool {
+	return func(k *models.APIKey) bool { return k.Key_id == keyId }
+}
+
+func (r *memoryAPIKeyRepository) Create(ctx context.Context, key *models.APIKey) error {
+	return r.table.insertUnique(*key, apiKeyByKeyID(key.Key_id))
+}
+
+func (r *memoryAPIKeyRepository) FindByKeyID(ctx context.Context, keyId string) (*models.APIKey, error) {
+	return r.table.find(apiKeyByKeyID(keyId))
+}
+
+func (r *memoryAPIKeyRepository) List(ctx context.Context, filter query.Filter, req pagination.Request) (*pagination.Page[models.APIKey], error) {
+	return r.table.cursorPage(func(*models.APIKey) bool { return true }, filter, req)
+}
+
+func (r *memoryAPIKeyRepository) Revoke(ctx context.Context, keyId string) (*models.APIKey, error) {
+	return r.table.update(apiKeyByKeyID(keyId), func(k *models.APIKey) {
+		if k.Revoked_at == nil {
+			revoked := now()
+			k.Revoked_at = &revoked
+		}
+	})
+}
+
+func (r *memoryAPIKeyRepository) Touch(ctx context.Context, keyId string, at time.Time) error {
+	_, err := r.table.update(apiKeyByKeyID(keyId), func(k *models.APIKey) {
+		k.Last_used_at = &at
+	})
+	return err
+}
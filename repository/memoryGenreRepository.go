@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"strings"
+
+	"github.com/genesdemon/golang-jwt-project/models"
+	"github.com/genesdemon/golang-jwt-project/pagination"
+	"github.com/genesdemon/golang-jwt-project/query"
+	"github.com/genesdemon/golang-jwt-project/search"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memoryGenreRepository struct {
+	table memoryTable[models.Genre]
+}
+
+func genreByID(id primitive.ObjectID) func(*models.Genre) bool {
+	return func(g *models.Genre) bool { return g.Id == id }
+}
+
+func (r *memoryGenreRepository) Create(ctx context.Context, genre *models.Genre) error {
+	r.table.insert(*genre)
+	return nil
+}
+
+func (r *memoryGenreRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Genre, error) {
+	return r.table.find(genreByID(id))
+}
+
+func (r *memoryGenreRepository) FindByIDs(ctx context.Context, ids []primitive.ObjectID) ([]models.Genre, error) {
+	wanted := map[primitive.ObjectID]bool{}
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	return r.table.filter(func(g *models.Genre) bool { return wanted[g.Id] }), nil
+}
+
+func (r *memoryGenreRepository) FindByName(ctx context.Context, name string) (*models.Genre, error) {
+	return r.table.find(func(g *models.Genre) bool { return strings.EqualFold(stringValue(g.Name), name) })
+}
+
+func (r *memoryGenreRepository) NameExists(ctx context.Context, name string) (bool, error) {
+	return r.table.exists(func(g *models.Genre) bool { return strings.EqualFold(stringValue(g.Name), name) }), nil
+}
+
+func (r *memoryGenreRepository) List(ctx context.Context, filter query.Filter, req pagination.Request) (*pagination.Page[models.Genre], error) {
+	return r.table.cursorPage(func(*models.Genre) bool { return true }, filter, req)
+}
+
+func (r *memoryGenreRepository) Update(ctx context.Context, id primitive.ObjectID, name *string) (*models.Genre, error) {
+	return r.table.update(genreByID(id), func(g *models.Genre) {
+		g.Name = name
+		g.Updated_at = now()
+	})
+}
+
+func (r *memoryGenreRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return r.table.remove(genreByID(id))
+}
+
+func (r *memoryGenreRepository) FindByNameTerms(ctx context.Context, terms []string) ([]models.Genre, error) {
+	return r.table.filter(func(g *models.Genre) bool { return search.MatchesAny(stringValue(g.Name), terms) }), nil
+}
@@ -0,0 +1,174 @@
+package repository
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/genesdemon/golang-jwt-project/models"
+	"github.com/genesdemon/golang-jwt-project/pagination"
+	"github.com/genesdemon/golang-jwt-project/query"
+	"github.com/genesdemon/golang-jwt-project/search"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memoryMovieRepository struct {
+	table memoryTable[models.Movie]
+}
+
+func movieByID(id primitive.ObjectID) func(*models.Movie) bool {
+	return func(m *models.Movie) bool { return m.Id == id }
+}
+
+func (r *memoryMovieRepository) Create(ctx context.Context, movie *models.Movie) error {
+	r.table.insert(*movie)
+	return nil
+}
+
+func (r *memoryMovieRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Movie, error) {
+	return r.table.find(movieByID(id))
+}
+
+func (r *memoryMovieRepository) NameExists(ctx context.Context, name string) (bool, error) {
+	return r.table.exists(func(m *models.Movie) bool { return strings.EqualFold(stringValue(m.Name), name) }), nil
+}
+
+func (r *memoryMovieRepository) List(ctx context.Context, filter query.Filter, req pagination.Request) (*pagination.Page[models.Movie], error) {
+	return r.table.cursorPage(func(*models.Movie) bool { return true }, filter, req)
+}
+
+func (r *memoryMovieRepository) Update(ctx context.Context, id primitive.ObjectID, movie *models.Movie) (*models.Movie, error) {
+	return r.table.update(movieByID(id), func(m *models.Movie) {
+		m.Name = movie.Name
+		m.Topic = movie.Topic
+		m.Genre_ids = movie.Genre_ids
+		m.Movie_URL = movie.Movie_URL
+		m.Updated_at = now()
+	})
+}
+
+func (r *memoryMovieRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return r.table.remove(movieByID(id))
+}
+
+func (r *memoryMovieRepository) Search(ctx context.Context, terms []string, genreIds []string, limit int) ([]ScoredMovie, error) {
+	inGenre := map[string]bool{}
+	for _, id := range genreIds {
+		inGenre[id] = true
+	}
+
+	scored := map[string]*ScoredMovie{}
+	for _, movie := range r.table.filter(func(*models.Movie) bool { return true }) {
+		score := search.FieldScore(stringValue(movie.Name), terms, search.NameWeight) +
+			search.FieldScore(stringValue(movie.Topic), terms, search.TopicWeight)
+		if score > 0 || inAnyGenre(movie.Genre_ids, inGenre) {
+			scored[movie.Id.Hex()] = &ScoredMovie{Movie: movie, Score: score}
+		}
+	}
+	return rankMovies(scored, genreIds, limit), nil
+}
+
+func (r *memoryMovieRepository) FindByGenres(ctx context.Context, genreIds []string, matchAll bool) ([]models.Movie, error) {
+	return r.table.filter(movieInGenres(genreIds, matchAll)), nil
+}
+
+func (r *memoryMovieRepository) ListByGenres(ctx context.Context, genreIds []string, matchAll bool, filter query.Filter, req pagination.Request) (*pagination.Page[models.Movie], error) {
+	return r.table.cursorPage(movieInGenres(genreIds, matchAll), filter, req)
+}
+
+func movieInGenres(genreIds []string, matchAll bool) func(*models.Movie) bool {
+	return func(m *models.Movie) bool {
+		for _, genreId := range genreIds {
+			found := hasGenre(m.Genre_ids, genreId)
+			if found != matchAll {
+				return found
+			}
+		}
+		return matchAll && len(genreIds) > 0
+	}
+}
+
+func hasGenre(genreIds []string, genreId string) bool {
+	for _, id := range genreIds {
+		if id == genreId {
+			return true
+		}
+	}
+	return false
+}
+
+func inAnyGenre(genreIds []string, set map[string]bool) bool {
+	for _, id := range genreIds {
+		if set[id] {
+			return true
+		}
+	}
+	return false
+}
+
+func movieInGenre(genreId string) func(*models.Movie) bool {
+	return func(m *models.Movie) bool { return hasGenre(m.Genre_ids, genreId) }
+}
+
+func movieOnlyInGenre(genreId string) func(*models.Movie) bool {
+	return func(m *models.Movie) bool { return len(m.Genre_ids) == 1 && m.Genre_ids[0] == genreId }
+}
+
+// withoutGenre returns a new slice so copies handed out earlier never change
+func withoutGenre(genreIds []string, genreId string) []string {
+	kept := []string{}
+	for _, id := range genreIds {
+		if id != genreId {
+			kept = append(kept, id)
+		}
+	}
+	return kept
+}
+
+func (r *memoryMovieRepository) CountByGenre(ctx context.Context, genreId string) (int64, error) {
+	return r.table.count(movieInGenre(genreId)), nil
+}
+
+func (r *memoryMovieRepository) DeleteByGenre(ctx context.Context, genreId string) (int64, error) {
+	return r.table.removeAll(movieOnlyInGenre(genreId)), nil
+}
+
+func (r *memoryMovieRepository) RemoveGenre(ctx context.Context, genreId string) (int64, error) {
+	return r.table.updateAll(movieInGenre(genreId), func(m *models.Movie) {
+		m.Genre_ids = withoutGenre(m.Genre_ids, genreId)
+		m.Updated_at = now()
+	}), nil
+}
+
+func (r *memoryMovieRepository) ReassignGenre(ctx context.Context, fromGenreId string, toGenreId string) (int64, error) {
+	return r.table.updateAll(movieInGenre(fromGenreId), func(m *models.Movie) {
+		if len(m.Genre_ids) == 1 {
+			m.Genre_ids = []string{toGenreId}
+		} else {
+			m.Genre_ids = withoutGenre(m.Genre_ids, fromGenreId)
+		}
+		m.Updated_at = now()
+	}), nil
+}
+
+func (r *memoryMovieRepository) ApplyRating(ctx context.Context, id primitive.ObjectID, removed *int, added *int) error {
+	_, err := r.table.update(movieByID(id), func(m *models.Movie) {
+		summary := &m.Ratings
+		//copy the histogram so copies handed out earlier never change under a reader
+		histogram := map[string]int{}
+		for key, count := range summary.Histogram {
+			histogram[key] = count
+		}
+		summary.Histogram = histogram
+		for _, change := range ratingChanges(removed, added) {
+			summary.Count += change.delta
+			summary.Sum += change.delta * change.rating
+			summary.Histogram[strconv.Itoa(change.rating)] += change.delta
+		}
+		summary.Average = 0
+		if summary.Count > 0 {
+			summary.Average = float64(summary.Sum) / float64(summary.Count)
+		}
+	})
+	return err
+}
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/genesdemon/golang-jwt-project/models"
+	"github.com/genesdemon/golang-jwt-project/pagination"
+	"github.com/genesdemon/golang-jwt-project/query"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memoryReviewRepository struct {
+	table memoryTable[models.Reviews]
+}
+
+func (r *memoryReviewRepository) Create(ctx context.Context, review *models.Reviews) error {
+	movieId, reviewerId := stringValue(review.Movie_id), stringValue(review.Reviewer_id)
+	return r.table.insertUnique(*review, func(rv *models.Reviews) bool {
+		return stringValue(rv.Movie_id) == movieId && stringValue(rv.Reviewer_id) == reviewerId
+	})
+}
+
+func reviewByID(id primitive.ObjectID) func(*models.Reviews) bool {
+	return func(rv *models.Reviews) bool { return rv.Id == id }
+}
+
+func (r *memoryReviewRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Reviews, error) {
+	return r.table.find(reviewByID(id))
+}
+
+func (r *memoryReviewRepository) Update(ctx context.Context, id primitive.ObjectID, edit *models.ReviewEdit, previous models.ReviewRevision) (*models.Reviews, error) {
+	return r.table.update(reviewByID(id), func(rv *models.Reviews) {
+		rv.Review = edit.Review
+		rv.Rating = edit.Rating
+		rv.Updated_at = previous.Edited_at
+		history := make([]models.ReviewRevision, len(rv.History), len(rv.History)+1)
+		copy(history, rv.History)
+		rv.History = append(history, previous)
+	})
+}
+
+func (r *memoryReviewRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return r.table.remove(reviewByID(id))
+}
+
+func reviewOfMovie(movieId string) func(*models.Reviews) bool {
+	return func(rv *models.Reviews) bool { return stringValue(rv.Movie_id) == movieId }
+}
+
+func (r *memoryReviewRepository) FindByMovie(ctx context.Context, movieId string, filter query.Filter, req pagination.Request) (*pagination.Page[models.Reviews], error) {
+	return r.table.cursorPage(reviewOfMovie(movieId), filter, req)
+}
+
+func (r *memoryReviewRepository) FindByReviewer(ctx context.Context, reviewerId string, filter query.Filter, req pagination.Request) (*pagination.Page[models.Reviews], error) {
+	reviewedBy := func(rv *models.Reviews) bool { return stringValue(rv.Reviewer_id) == reviewerId }
+	return r.table.cursorPage(reviewedBy, filter, req)
+}
+
+func (r *memoryReviewRepository) CountByMovie(ctx context.Context, movieId string) (int64, error) {
+	return r.table.count(reviewOfMovie(movieId)), nil
+}
+
+func (r *memoryReviewRepository) DeleteByMovie(ctx context.Context, movieId string) (int64, error) {
+	return r.table.removeAll(reviewOfMovie(movieId)), nil
+}
@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/genesdemon/golang-jwt-project/models"
+	"github.com/genesdemon/golang-jwt-project/pagination"
+	"github.com/genesdemon/golang-jwt-project/query"
+)
+
+type memoryRoleRepository struct {
+	table memoryTable[models.Role]
+}
+
+func roleByName(name string) func(*models.Role) bool {
+	return func(r *models.Role) bool { return stringValue(r.Name) == name }
+}
+
+func (r *memoryRoleRepository) Create(ctx context.Context, role *models.Role) error {
+	return r.table.insertUnique(*role, roleByName(stringValue(role.Name)))
+}
+
+func (r *memoryRoleRepository) FindByName(ctx context.Context, name string) (*models.Role, error) {
+	return r.table.find(roleByName(name))
+}
+
+func (r *memoryRoleRepository) FindByNames(ctx context.Context, names []string) ([]models.Role, error) {
+	wanted := map[string]bool{}
+	for _, name := range names {
+		wanted[name] = true
+	}
+	return r.table.filter(func(role *models.Role) bool { return wanted[stringValue(role.Name)] }), nil
+}
+
+func (r *memoryRoleRepository) List(ctx context.Context, filter query.Filter, req pagination.Request) (*pagination.Page[models.Role], error) {
+	return r.table.cursorPage(func(*models.Role) bool { return true }, filter, req)
+}
+
+func (r *memoryRoleRepository) SetPermissions(ctx context.Context, name string, permissions []string) (*models.Role, error) {
+	return r.table.update(roleByName(name), func(role *models.Role) {
+		role.Permissions = append([]string{}, permissions...)
+		role.Updated_at = now()
+	})
+}
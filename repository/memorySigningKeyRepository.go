@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/genesdemon/golang-jwt-project/models"
+)
+
+type memorySigningKeyRepository struct {
+	table memoryTable[models.SigningKey]
+}
+
+func (r *memorySigningKeyRepository) Create(ctx context.Context, key *models.SigningKey) error {
+	return r.table.insertUnique(*key, func(k *models.SigningKey) bool { return k.Kid == key.Kid })
+}
+
+func (r *memorySigningKeyRepository) ListUnexpired(ctx context.Context, at time.Time) ([]models.SigningKey, error) {
+	keys := r.table.filter(func(k *models.SigningKey) bool { return k.Expires_at.After(at) })
+	sort.SliceStable(keys, func(i, j int) bool { return keys[i].Activates_at.Before(keys[j].Activates_at) })
+	return keys, nil
+}
+
+func (r *memorySigningKeyRepository) DeleteExpired(ctx context.Context, at time.Time) (int64, error) {
+	return r.table.removeAll(func(k *models.SigningKey) bool { return !k.Expires_at.After(at) }), nil
+}
+
+func (r *memorySigningKeyRepository) SetPrivateKey(ctx context.Context, kid string, privateKey []byte, encryption string) error {
+	_, err := r.table.update(func(k *models.SigningKey) bool { return k.Kid == kid }, func(k *models.SigningKey) {
+		k.Private_key = privateKey
+		k.Private_key_encryption = encryption
+	})
+	return err
+}
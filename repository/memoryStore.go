@@ -0,0 +1,219 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/genesdemon/golang-jwt-project/pagination"
+	"github.com/genesdemon/golang-jwt-project/query"
+)
+
+// NewMemoryStore builds a Store that keeps everything in process memory. It is
+// meant for tests and local demos; nothing survives a restart.
+func NewMemoryStore() *Store {
+	return &Store{
+		Users:        &memoryUserRepository{},
+		Roles:        &memoryRoleRepository{},
+		APIKeys:      &memoryAPIKeyRepository{},
+		SigningKeys:  &memorySigningKeyRepository{},
+		Genres:       &memoryGenreRepository{},
+		Movies:       &memoryMovieRepository{},
+		Reviews:      &memoryReviewRepository{},
+		Transactions: &memoryTransactor{},
+	}
+}
+
+// memoryTransactor only runs one transaction at a time. It cannot undo
+// writes, which the memory repositories never need: in every group of writes
+// only the first one can fail.
+type memoryTransactor struct {
+	mu sync.Mutex
+}
+
+func (t *memoryTransactor) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return fn(ctx)
+}
+
+// memoryTable is an insertion-ordered, mutex guarded list of documents.
+type memoryTable[T any] struct {
+	mu    sync.RWMutex
+	items []T
+}
+
+func (t *memoryTable[T]) insert(item T) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.items = append(t.items, item)
+}
+
+// insertUnique stores item unless a document matching conflict exists.
+func (t *memoryTable[T]) insertUnique(item T, conflict func(*T) bool) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i := range t.items {
+		if conflict(&t.items[i]) {
+			return ErrDuplicate
+		}
+	}
+	t.items = append(t.items, item)
+	return nil
+}
+
+// find returns a copy of the first document matching the predicate.
+func (t *memoryTable[T]) find(match func(*T) bool) (*T, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for i := range t.items {
+		if match(&t.items[i]) {
+			item := t.items[i]
+			return &item, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (t *memoryTable[T]) filter(match func(*T) bool) []T {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	result := []T{}
+	for i := range t.items {
+		if match(&t.items[i]) {
+			result = append(result, t.items[i])
+		}
+	}
+	return result
+}
+
+func (t *memoryTable[T]) count(match func(*T) bool) int64 {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	var count int64
+	for i := range t.items {
+		if match(&t.items[i]) {
+			count++
+		}
+	}
+	return count
+}
+
+func (t *memoryTable[T]) exists(match func(*T) bool) bool {
+	_, err := t.find(match)
+	return err == nil
+}
+
+// cursorPage returns the page of documents matching both match and filter
+// that req asks for, in the same order as the Mongo store.
+func (t *memoryTable[T]) cursorPage(match func(*T) bool, filter query.Filter, req pagination.Request) (*pagination.Page[T], error) {
+	type entry struct {
+		item T
+		key  pagination.Key
+	}
+	entries := []entry{}
+	for _, item := range t.filter(match) {
+		doc, err := query.Document(item)
+		if err != nil {
+			return nil, err
+		}
+		if filter.Match(doc) {
+			entries = append(entries, entry{item: item, key: pagination.KeyOf(doc, req.Sort)})
+		}
+	}
+
+	backward := req.Cursor != nil && req.Cursor.Backward
+	sort.SliceStable(entries, func(i, j int) bool {
+		cmp := pagination.Compare(entries[i].key, entries[j].key, req.Sort)
+		if backward {
+			return cmp > 0
+		}
+		return cmp < 0
+	})
+
+	fetched := []T{}
+	for _, e := range entries {
+		if len(fetched) > req.Limit {
+			break
+		}
+		if req.Cursor != nil {
+			cmp := pagination.Compare(e.key, req.Cursor.Key, req.Sort)
+			if backward && cmp >= 0 || !backward && cmp <= 0 {
+				continue
+			}
+		}
+		fetched = append(fetched, e.item)
+	}
+	result, err := pagination.Build(req, fetched)
+	if err != nil {
+		return nil, err
+	}
+	if req.WithTotal {
+		total := int64(len(entries))
+		result.Total = &total
+	}
+	return result, nil
+}
+
+// update applies change to the first matching document and returns a copy of
+// the result.
+func (t *memoryTable[T]) update(match func(*T) bool, change func(*T)) (*T, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i := range t.items {
+		if match(&t.items[i]) {
+			change(&t.items[i])
+			item := t.items[i]
+			return &item, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// updateAll applies change to every matching document and returns how many
+// were changed.
+func (t *memoryTable[T]) updateAll(match func(*T) bool, change func(*T)) int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var changed int64
+	for i := range t.items {
+		if match(&t.items[i]) {
+			change(&t.items[i])
+			changed++
+		}
+	}
+	return changed
+}
+
+func (t *memoryTable[T]) removeAll(match func(*T) bool) int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	kept := t.items[:0]
+	for i := range t.items {
+		if !match(&t.items[i]) {
+			kept = append(kept, t.items[i])
+		}
+	}
+	removed := int64(len(t.items) - len(kept))
+	t.items = kept
+	return removed
+}
+
+func (t *memoryTable[T]) remove(match func(*T) bool) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i := range t.items {
+		if match(&t.items[i]) {
+			t.items = append(t.items[:i], t.items[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func stringValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
@@ -0,0 +1,269 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/genesdemon/golang-jwt-project/models"
+	"github.com/genesdemon/golang-jwt-project/pagination"
+	"github.com/genesdemon/golang-jwt-project/query"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// seedMovies stores movies whose ratings tie in places, so paging has to fall
+// back on the id to keep every movie in one position.
+func seedMovies(t *testing.T, movies MovieRepository, ratings []float64) []models.Movie {
+	t.Helper()
+	seeded := []models.Movie{}
+	for i, rating := range ratings {
+		name := fmt.Sprintf("movie %d", i)
+		movie := models.Movie{
+			Id:        primitive.NewObjectID(),
+			Name:      &name,
+			Genre_ids: []string{fmt.Sprint("genre-", i%2)},
+			Ratings:   models.RatingSummary{Average: rating, Count: 1},
+		}
+		if err := movies.Create(context.Background(), &movie); err != nil {
+			t.Fatal(err)
+		}
+		seeded = append(seeded, movie)
+	}
+	return seeded
+}
+
+func names(movies []models.Movie) []string {
+	out := []string{}
+	for _, m := range movies {
+		out = append(out, *m.Name)
+	}
+	return out
+}
+
+func TestMemoryCursorPageWalksEveryPageInOrder(t *testing.T) {
+	store := NewMemoryStore()
+	seeded := seedMovies(t, store.Movies, []float64{7, 9, 7, 5, 9, 7, 8})
+	order := query.Sort{{Key: "ratings.average", Desc: true}}
+
+	//best rated first, ties in id order
+	want := append([]models.Movie{}, seeded...)
+	sort.SliceStable(want, func(i, j int) bool {
+		if want[i].Ratings.Average != want[j].Ratings.Average {
+			return want[i].Ratings.Average > want[j].Ratings.Average
+		}
+		return want[i].Id.Hex() < want[j].Id.Hex()
+	})
+
+	tests := []struct {
+		name  string
+		limit int
+	}{
+		{"pages that divide the list", 7},
+		{"uneven pages", 3},
+		{"one per page", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := pagination.Request{Limit: tt.limit, Sort: order}
+			var pages [][]models.Movie
+			var forward []models.Movie
+			var lastPrev string
+			for {
+				page, err := store.Movies.List(context.Background(), query.Filter{}, req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				pages = append(pages, page.Items)
+				forward = append(forward, page.Items...)
+				lastPrev = page.Prev
+				if page.Next == "" {
+					break
+				}
+				if req.Cursor, err = pagination.Decode(page.Next, order); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !reflect.DeepEqual(names(forward), names(want)) {
+				t.Fatalf("forward = %v, want %v", names(forward), names(want))
+			}
+
+			//walking back from the last page with the prev cursors gives the
+			//same pages
+			prev := lastPrev
+			for i := len(pages) - 2; i >= 0; i-- {
+				if prev == "" {
+					t.Fatalf("page %d has no prev cursor", i+1)
+				}
+				cursor, err := pagination.Decode(prev, order)
+				if err != nil {
+					t.Fatal(err)
+				}
+				page, err := store.Movies.List(context.Background(), query.Filter{}, pagination.Request{Limit: tt.limit, Sort: order, Cursor: cursor})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(names(page.Items), names(pages[i])) {
+					t.Fatalf("page %d backward = %v, want %v", i, names(page.Items), names(pages[i]))
+				}
+				prev = page.Prev
+			}
+			if prev != "" {
+				t.Errorf("first page reached backward has prev cursor %q", prev)
+			}
+		})
+	}
+}
+
+func TestMemoryCursorPageFiltersAndCounts(t *testing.T) {
+	store := NewMemoryStore()
+	seedMovies(t, store.Movies, []float64{7, 9, 7, 5, 9, 7, 8})
+
+	filter := query.Filter{
+		{Key: "genre_ids", Op: "$eq", Value: "genre-0"},
+		{Key: "ratings.average", Op: "$gte", Value: 7.0},
+	}
+	req := pagination.Request{Limit: 2, Sort: query.Sort{{Key: "name"}}, WithTotal: true}
+	page, err := store.Movies.List(context.Background(), filter, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//movies 0, 2, 4 and 6 are in genre-0 and all rated 7 or more
+	if got := names(page.Items); !reflect.DeepEqual(got, []string{"movie 0", "movie 2"}) {
+		t.Errorf("items = %v", got)
+	}
+	if page.Total == nil || *page.Total != 4 {
+		t.Errorf("total = %v, want 4", page.Total)
+	}
+	if page.Next == "" || page.Prev != "" {
+		t.Errorf("next = %q, prev = %q, want only next", page.Next, page.Prev)
+	}
+}
+
+// Each device signs in with its own refresh token family, so reuse on one
+// device must not end the sessions of the others.
+func TestMemorySessionsArePerDevice(t *testing.T) {
+	ctx := context.Background()
+	users := NewMemoryStore().Users
+	if err := users.Create(ctx, &models.User{User_id: "uid-1"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, device := range []string{"phone", "laptop"} {
+		if err := users.UpdateTokens(ctx, "uid-1", "access", device+"-1", device); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		presented string
+		next      string
+		want      bool
+	}{
+		{"phone rotates", "phone-1", "phone-2", true},
+		{"laptop rotates after the phone", "laptop-1", "laptop-2", true},
+		{"an exchanged token is refused", "phone-1", "phone-3", false},
+		{"the current token rotates", "phone-2", "phone-3", true},
+	}
+	for _, tt := range tests {
+		rotated, err := users.RotateTokens(ctx, "uid-1", tt.presented, "access", tt.next)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rotated != tt.want {
+			t.Errorf("%s: rotated = %v, want %v", tt.name, rotated, tt.want)
+		}
+	}
+
+	if err := users.RevokeFamily(ctx, "phone"); err != nil {
+		t.Fatal(err)
+	}
+	user, err := users.FindByUserID(ctx, "uid-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.HasSession("phone") || !user.HasSession("laptop") {
+		t.Errorf("sessions after revoking the phone = %+v", user.Sessions)
+	}
+	if _, err := users.FindByRefreshFamily(ctx, "laptop"); err != nil {
+		t.Errorf("the laptop session is gone: %v", err)
+	}
+
+	//signing in on more devices than allowed ends the oldest sessions
+	for i := 0; i < maxSessions; i++ {
+		if err := users.UpdateTokens(ctx, "uid-1", "access", fmt.Sprint("refresh-", i), fmt.Sprint("device-", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	user, _ = users.FindByUserID(ctx, "uid-1")
+	if len(user.Sessions) != maxSessions || user.HasSession("laptop") || !user.HasSession("device-0") {
+		t.Errorf("%d sessions kept, laptop kept %v", len(user.Sessions), user.HasSession("laptop"))
+	}
+}
+
+func TestMemoryApplyRating(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	name := "unrated"
+	movie := models.Movie{Id: primitive.NewObjectID(), Name: &name}
+	if err := store.Movies.Create(ctx, &movie); err != nil {
+		t.Fatal(err)
+	}
+	rating := func(r int) *int { return &r }
+
+	tests := []struct {
+		name      string
+		removed   *int
+		added     *int
+		wantCount int
+		wantSum   int
+		wantAt    map[string]int
+	}{
+		{"a new review adds its rating", nil, rating(7), 1, 7, map[string]int{"7": 1}},
+		{"another review", nil, rating(4), 2, 11, map[string]int{"4": 1, "7": 1}},
+		{"an edit replaces a rating", rating(7), rating(9), 2, 13, map[string]int{"4": 1, "7": 0, "9": 1}},
+		{"an edit keeping the rating changes nothing", rating(9), rating(9), 2, 13, map[string]int{"9": 1}},
+		{"a deleted review removes its rating", rating(4), nil, 1, 9, map[string]int{"4": 0, "9": 1}},
+	}
+	for _, tt := range tests {
+		if err := store.Movies.ApplyRating(ctx, movie.Id, tt.removed, tt.added); err != nil {
+			t.Fatal(err)
+		}
+		got, err := store.Movies.FindByID(ctx, movie.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		summary := got.Ratings
+		if summary.Count != tt.wantCount || summary.Sum != tt.wantSum {
+			t.Errorf("%s: count %d, sum %d, want %d and %d", tt.name, summary.Count, summary.Sum, tt.wantCount, tt.wantSum)
+		}
+		for key, want := range tt.wantAt {
+			if summary.Histogram[key] != want {
+				t.Errorf("%s: histogram[%s] = %d, want %d", tt.name, key, summary.Histogram[key], want)
+			}
+		}
+		if wantAverage := float64(tt.wantSum) / float64(tt.wantCount); summary.Average != wantAverage {
+			t.Errorf("%s: average %v, want %v", tt.name, summary.Average, wantAverage)
+		}
+	}
+}
+
+// Two confirmations racing each other must not both hand out recovery codes.
+func TestMemoryEnableTOTPOnlyOnce(t *testing.T) {
+	ctx := context.Background()
+	users := NewMemoryStore().Users
+	if err := users.Create(ctx, &models.User{User_id: "uid-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.EnableTOTP(ctx, "uid-1", 1, []string{"first"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.EnableTOTP(ctx, "uid-1", 2, []string{"second"}); err != ErrNotFound {
+		t.Fatalf("second EnableTOTP: err = %v, want ErrNotFound", err)
+	}
+	user, _ := users.FindByUserID(ctx, "uid-1")
+	if !reflect.DeepEqual(user.Recovery_codes, []string{"first"}) {
+		t.Errorf("recovery codes = %v, want the first ones", user.Recovery_codes)
+	}
+}
@@ -0,0 +1,352 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/genesdemon/golang-jwt-project/models"
+	"github.com/genesdemon/golang-jwt-project/pagination"
+	"github.com/genesdemon/golang-jwt-project/query"
+)
+
+type memoryUserRepository struct {
+	table memoryTable[models.User]
+}
+
+func byUserID(userId string) func(*models.User) bool {
+	return func(u *models.User) bool { return u.User_id == userId }
+}
+
+func (r *memoryUserRepository) Create(ctx context.Context, user *models.User) error {
+	r.table.insert(*user)
+	return nil
+}
+
+func (r *memoryUserRepository) FindByUserID(ctx context.Context, userId string) (*models.User, error) {
+	return r.table.find(byUserID(userId))
+}
+
+func (r *memoryUserRepository) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	return r.table.find(func(u *models.User) bool { return stringValue(u.Email) == email })
+}
+
+func (r *memoryUserRepository) FindByRefreshFamily(ctx context.Context, family string) (*models.User, error) {
+	return r.table.find(func(u *models.User) bool { return u.HasSession(family) })
+}
+
+func (r *memoryUserRepository) FindPrivate(ctx context.Context, userId string) (*models.PrivateUser, error) {
+	return private(r.table.find(byUserID(userId)))
+}
+
+func (r *memoryUserRepository) FindPublic(ctx context.Context, userId string) (*models.PublicUser, error) {
+	user, err := r.table.find(byUserID(userId))
+	if err != nil {
+		return nil, err
+	}
+	return models.NewPublicUser(user), nil
+}
+
+func (r *memoryUserRepository) EmailExists(ctx context.Context, email string) (bool, error) {
+	return r.table.exists(func(u *models.User) bool { return strings.EqualFold(stringValue(u.Email), email) }), nil
+}
+
+func (r *memoryUserRepository) UsernameExists(ctx context.Context, username string) (bool, error) {
+	return r.table.exists(func(u *models.User) bool { return strings.EqualFold(stringValue(u.Username), username) }), nil
+}
+
+func (r *memoryUserRepository) List(ctx context.Context, filter query.Filter, req pagination.Request) (*pagination.Page[models.PrivateUser], error) {
+	page, err := r.table.cursorPage(func(*models.User) bool { return true }, filter, req)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]models.PrivateUser, 0, len(page.Items))
+	for i := range page.Items {
+		items = append(items, *models.NewPrivateUser(&page.Items[i]))
+	}
+	return &pagination.Page[models.PrivateUser]{Items: items, Next: page.Next, Prev: page.Prev, Total: page.Total}, nil
+}
+
+func (r *memoryUserRepository) UpdateProfile(ctx context.Context, userId string, update models.UserUpdate) (*models.PrivateUser, error) {
+	return private(r.table.update(byUserID(userId), func(u *models.User) {
+		if update.Name != nil {
+			u.Name = update.Name
+		}
+		if update.Username != nil {
+			u.Username = update.Username
+		}
+		if update.Email != nil {
+			u.Email = update.Email
+			u.Email_verified = false
+			u.Verify_token_hash = nil
+			u.Verify_expires_at = nil
+		}
+		u.Updated_at = now()
+	}))
+}
+
+func (r *memoryUserRepository) UpdateTokens(ctx context.Context, userId string, token string, refreshToken string, family string) error {
+	_, err := r.table.update(byUserID(userId), func(u *models.User) {
+		sessions := append(append([]models.Session{}, u.Sessions...), models.Session{Family: family, Refresh_token: refreshToken, Created_at: now()})
+		if len(sessions) > maxSessions {
+			sessions = sessions[len(sessions)-maxSessions:]
+		}
+		u.Token = &token
+		u.Sessions = sessions
+		u.Updated_at = now()
+	})
+	if err == ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+func (r *memoryUserRepository) RotateTokens(ctx context.Context, userId string, presentedRefreshToken string, token string, refreshToken string) (bool, error) {
+	match := func(u *models.User) bool {
+		return u.User_id == userId && sessionIndex(u, presentedRefreshToken) >= 0
+	}
+	_, err := r.table.update(match, func(u *models.User) {
+		sessions := append([]models.Session{}, u.Sessions...)
+		sessions[sessionIndex(u, presentedRefreshToken)].Refresh_token = refreshToken
+		u.Token = &token
+		u.Sessions = sessions
+		u.Updated_at = now()
+	})
+	if err == ErrNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *memoryUserRepository) RevokeFamily(ctx context.Context, family string) error {
+	match := func(u *models.User) bool { return u.HasSession(family) }
+	_, err := r.table.update(match, func(u *models.User) {
+		kept := []models.Session{}
+		for _, session := range u.Sessions {
+			if session.Family != family {
+				kept = append(kept, session)
+			}
+		}
+		u.Sessions = kept
+		u.Updated_at = now()
+	})
+	if err == ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+func (r *memoryUserRepository) RevokeAll(ctx context.Context, userId string) error {
+	_, err := r.table.update(byUserID(userId), func(u *models.User) {
+		u.Token_version++
+		clearTokens(u)
+	})
+	return err
+}
+
+func (r *memoryUserRepository) SetResetToken(ctx context.Context, userId string, hash string, expiresAt time.Time) error {
+	_, err := r.table.update(byUserID(userId), func(u *models.User) {
+		u.Reset_token_hash = &hash
+		u.Reset_expires_at = &expiresAt
+	})
+	return err
+}
+
+func (r *memoryUserRepository) ConsumeResetToken(ctx context.Context, hash string, now time.Time) (*models.User, error) {
+	return r.table.update(func(u *models.User) bool {
+		return u.Reset_token_hash != nil && *u.Reset_token_hash == hash && u.Reset_expires_at.After(now)
+	}, func(u *models.User) {
+		u.Reset_token_hash = nil
+		u.Reset_expires_at = nil
+	})
+}
+
+func (r *memoryUserRepository) SetVerifyToken(ctx context.Context, userId string, hash string, expiresAt time.Time) error {
+	_, err := r.table.update(byUserID(userId), func(u *models.User) {
+		u.Verify_token_hash = &hash
+		u.Verify_expires_at = &expiresAt
+	})
+	return err
+}
+
+func (r *memoryUserRepository) ConsumeVerifyToken(ctx context.Context, hash string, now time.Time) (*models.User, error) {
+	return r.table.update(func(u *models.User) bool {
+		return u.Verify_token_hash != nil && *u.Verify_token_hash == hash && u.Verify_expires_at.After(now)
+	}, func(u *models.User) {
+		u.Verify_token_hash = nil
+		u.Verify_expires_at = nil
+		u.Email_verified = true
+		u.Updated_at = now
+	})
+}
+
+func (r *memoryUserRepository) RecordFailedLogin(ctx context.Context, userId string, now time.Time, window time.Duration) (int, error) {
+	user, err := r.table.update(byUserID(userId), func(u *models.User) {
+		if u.Last_failed_login == nil || !u.Last_failed_login.After(now.Add(-window)) {
+			u.Failed_logins = 0
+		}
+		u.Failed_logins++
+		u.Last_failed_login = &now
+	})
+	if err != nil {
+		return 0, err
+	}
+	return user.Failed_logins, nil
+}
+
+func (r *memoryUserRepository) LockUntil(ctx context.Context, userId string, until time.Time) error {
+	_, err := r.table.update(byUserID(userId), func(u *models.User) {
+		u.Locked_until = &until
+	})
+	return err
+}
+
+func (r *memoryUserRepository) ClearFailedLogins(ctx context.Context, userId string) (*models.PrivateUser, error) {
+	return private(r.table.update(byUserID(userId), func(u *models.User) {
+		u.Failed_logins = 0
+		u.Last_failed_login = nil
+		u.Locked_until = nil
+		u.Updated_at = now()
+	}))
+}
+
+func (r *memoryUserRepository) SetTOTPSecret(ctx context.Context, userId string, secret string) error {
+	_, err := r.table.update(func(u *models.User) bool {
+		return u.User_id == userId && !u.Mfa_enabled
+	}, func(u *models.User) {
+		u.Totp_secret = &secret
+	})
+	return err
+}
+
+func (r *memoryUserRepository) EnableTOTP(ctx context.Context, userId string, step int64, recoveryHashes []string) error {
+	_, err := r.table.update(func(u *models.User) bool {
+		return u.User_id == userId && !u.Mfa_enabled
+	}, func(u *models.User) {
+		u.Mfa_enabled = true
+		u.Totp_last_step = step
+		u.Recovery_codes = append([]string{}, recoveryHashes...)
+		u.Updated_at = now()
+	})
+	return err
+}
+
+func (r *memoryUserRepository) UseTOTPStep(ctx context.Context, userId string, step int64) (bool, error) {
+	_, err := r.table.update(func(u *models.User) bool {
+		return u.User_id == userId && u.Totp_last_step < step
+	}, func(u *models.User) {
+		u.Totp_last_step = step
+	})
+	if err == ErrNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *memoryUserRepository) UseRecoveryCode(ctx context.Context, userId string, hash string) (bool, error) {
+	used := false
+	_, err := r.table.update(byUserID(userId), func(u *models.User) {
+		kept := []string{}
+		for _, code := range u.Recovery_codes {
+			if code == hash {
+				used = true
+				continue
+			}
+			kept = append(kept, code)
+		}
+		u.Recovery_codes = kept
+	})
+	return used, err
+}
+
+func (r *memoryUserRepository) DisableTOTP(ctx context.Context, userId string) error {
+	_, err := r.table.update(byUserID(userId), func(u *models.User) {
+		u.Mfa_enabled = false
+		u.Totp_secret = nil
+		u.Totp_last_step = 0
+		u.Recovery_codes = nil
+		u.Updated_at = now()
+	})
+	return err
+}
+
+func (r *memoryUserRepository) SetPassword(ctx context.Context, userId string, passwordHash string) error {
+	_, err := r.table.update(byUserID(userId), func(u *models.User) {
+		u.Password = &passwordHash
+		u.Token_version++
+		u.Failed_logins = 0
+		u.Last_failed_login = nil
+		u.Locked_until = nil
+		clearTokens(u)
+	})
+	return err
+}
+
+func (r *memoryUserRepository) AddRole(ctx context.Context, userId string, role string) (*models.PrivateUser, error) {
+	return private(r.table.update(byUserID(userId), func(u *models.User) {
+		for _, assigned := range u.Roles {
+			if assigned == role {
+				return
+			}
+		}
+		u.Roles = append(append([]string{}, u.Roles...), role)
+		u.Updated_at = now()
+	}))
+}
+
+func (r *memoryUserRepository) RemoveRole(ctx context.Context, userId string, role string) (*models.PrivateUser, error) {
+	return private(r.table.update(byUserID(userId), func(u *models.User) {
+		kept := []string{}
+		for _, assigned := range u.Roles {
+			if assigned != role {
+				kept = append(kept, assigned)
+			}
+		}
+		u.Roles = kept
+		u.Updated_at = now()
+	}))
+}
+
+func (r *memoryUserRepository) SetUserType(ctx context.Context, userId string, userType string, revoke []string) (*models.PrivateUser, error) {
+	revoked := map[string]bool{}
+	for _, role := range revoke {
+		revoked[role] = true
+	}
+	return private(r.table.update(byUserID(userId), func(u *models.User) {
+		kept := []string{}
+		for _, assigned := range u.Roles {
+			if !revoked[assigned] {
+				kept = append(kept, assigned)
+			}
+		}
+		u.Roles = kept
+		u.User_type = &userType
+		u.Updated_at = now()
+	}))
+}
+
+func clearTokens(u *models.User) {
+	u.Token = nil
+	u.Sessions = nil
+	u.Updated_at = now()
+}
+
+// sessionIndex returns where the session holding refreshToken is among the
+// user's sessions, or -1.
+func sessionIndex(u *models.User, refreshToken string) int {
+	for i, session := range u.Sessions {
+		if session.Refresh_token == refreshToken {
+			return i
+		}
+	}
+	return -1
+}
+
+// private keeps only what the owner of the account may see of a user read
+// from the table, like the projections of the Mongo store.
+func private(user *models.User, err error) (*models.PrivateUser, error) {
+	if err != nil {
+		return nil, err
+	}
+	return models.NewPrivateUser(user), nil
+}
@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/genesdemon/golang-jwt-project/models"
+	"github.com/genesdemon/golang-jwt-project/pagination"
+	"github.com/genesdemon/golang-jwt-project/query"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type mongoAPIKeyRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoAPIKeyRepository) Create(ctx context.Context, key *models.APIKey) error {
+	_, err := r.collection.InsertOne(ctx, key)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+func (r *mongoAPIKeyRepository) FindByKeyID(ctx context.Context, keyId string) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := findOne(ctx, r.collection, bson.M{"key_id": keyId}, &key); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *mongoAPIKeyRepository) List(ctx context.Context, filter query.Filter, req pagination.Request) (*pagination.Page[models.APIKey], error) {
+	return cursorPage[models.APIKey](ctx, r.collection, bson.M{}, filter, req)
+}
+
+func (r *mongoAPIKeyRepository) Revoke(ctx context.Context, keyId string) (*models.APIKey, error) {
+	//revoking twice keeps the first revocation time
+	update := bson.M{"$set": bson.M{"revoked_at": now()}}
+	if _, err := r.collection.UpdateOne(ctx, bson.M{"key_id": keyId, "revoked_at": nil}, update); err != nil {
+		return nil, err
+	}
+	return r.FindByKeyID(ctx, keyId)
+}
+
+func (r *mongoAPIKeyRepository) Touch(ctx context.Context, keyId string, at time.Time) error {
+	_, err := r.collection.UpdateOne(ctx, bson.M{"key_id": keyId}, bson.M{"$set": bson.M{"last_used_at": at}})
+	return err
+}
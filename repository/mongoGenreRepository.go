@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/genesdemon/golang-jwt-project/models"
+	"github.com/genesdemon/golang-jwt-project/pagination"
+	"github.com/genesdemon/golang-jwt-project/query"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type mongoGenreRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoGenreRepository) Create(ctx context.Context, genre *models.Genre) error {
+	_, err := r.collection.InsertOne(ctx, genre)
+	return err
+}
+
+func (r *mongoGenreRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Genre, error) {
+	var genre models.Genre
+	if err := findOne(ctx, r.collection, bson.M{"_id": id}, &genre); err != nil {
+		return nil, err
+	}
+	return &genre, nil
+}
+
+func (r *mongoGenreRepository) FindByIDs(ctx context.Context, ids []primitive.ObjectID) ([]models.Genre, error) {
+	genres := []models.Genre{}
+	if len(ids) == 0 {
+		return genres, nil
+	}
+	err := findAll(ctx, r.collection, bson.M{"_id": bson.M{"$in": ids}}, &genres)
+	return genres, err
+}
+
+func (r *mongoGenreRepository) FindByName(ctx context.Context, name string) (*models.Genre, error) {
+	var genre models.Genre
+	if err := findOne(ctx, r.collection, bson.M{"name": equalFold(name)}, &genre); err != nil {
+		return nil, err
+	}
+	return &genre, nil
+}
+
+func (r *mongoGenreRepository) NameExists(ctx context.Context, name string) (bool, error) {
+	count, err := r.collection.CountDocuments(ctx, bson.M{"name": equalFold(name)})
+	return count > 0, err
+}
+
+func (r *mongoGenreRepository) List(ctx context.Context, filter query.Filter, req pagination.Request) (*pagination.Page[models.Genre], error) {
+	return cursorPage[models.Genre](ctx, r.collection, bson.M{}, filter, req)
+}
+
+func (r *mongoGenreRepository) Update(ctx context.Context, id primitive.ObjectID, name *string) (*models.Genre, error) {
+	update := bson.M{"name": name, "updated_at": now()}
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": update})
+	if err != nil {
+		return nil, err
+	}
+	if result.MatchedCount < 1 {
+		return nil, ErrNotFound
+	}
+	return r.FindByID(ctx, id)
+}
+
+func (r *mongoGenreRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return deleteByID(ctx, r.collection, id)
+}
+
+func (r *mongoGenreRepository) FindByNameTerms(ctx context.Context, terms []string) ([]models.Genre, error) {
+	genres := []models.Genre{}
+	if len(terms) == 0 {
+		return genres, nil
+	}
+	var anyTerm []bson.M
+	for _, term := range terms {
+		anyTerm = append(anyTerm, bson.M{"name": primitive.Regex{Pattern: `\b` + regexp.QuoteMeta(term), Options: "i"}})
+	}
+	err := findAll(ctx, r.collection, bson.M{"$or": anyTerm}, &genres)
+	return genres, err
+}
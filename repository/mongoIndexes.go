@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/genesdemon/golang-jwt-project/search"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// EnsureIndexes creates the indexes the Mongo repositories rely on. Creating
+// an index that already exists is a no-op, so it is safe to run on every start.
+func EnsureIndexes(ctx context.Context, db *mongo.Database) error {
+	movieText := mongo.IndexModel{
+		Keys: bson.D{{Key: "name", Value: "text"}, {Key: "topic", Value: "text"}},
+		Options: options.Index().
+			SetName("movie_text").
+			SetWeights(bson.D{{Key: "name", Value: search.NameWeight}, {Key: "topic", Value: search.TopicWeight}}),
+	}
+	if _, err := db.Collection("movie").Indexes().CreateOne(ctx, movieText); err != nil {
+		return err
+	}
+
+	movieGenres := mongo.IndexModel{
+		Keys:    bson.D{{Key: "genre_ids", Value: 1}},
+		Options: options.Index().SetName("movie_genre_ids"),
+	}
+	if _, err := db.Collection("movie").Indexes().CreateOne(ctx, movieGenres); err != nil {
+		return err
+	}
+
+	//genre matches of a search are taken by name, see Search
+	movieGenreNames := mongo.IndexModel{
+		Keys:    bson.D{{Key: "genre_ids", Value: 1}, {Key: "name", Value: 1}, {Key: "_id", Value: 1}},
+		Options: options.Index().SetName("movie_genre_names").SetCollation(nameCollation),
+	}
+	if _, err := db.Collection("movie").Indexes().CreateOne(ctx, movieGenreNames); err != nil {
+		return err
+	}
+
+	//keyset order used by the cursor paginated lists
+	listOrder := bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}
+	for _, name := range []string{"user", "role", "api_key", "genre", "movie", "review"} {
+		model := mongo.IndexModel{Keys: listOrder, Options: options.Index().SetName("list_order")}
+		if _, err := db.Collection(name).Indexes().CreateOne(ctx, model); err != nil {
+			return err
+		}
+	}
+	reviewsByReviewer := mongo.IndexModel{
+		Keys:    bson.D{{Key: "reviewer_id", Value: 1}, {Key: "created_at", Value: 1}, {Key: "_id", Value: 1}},
+		Options: options.Index().SetName("review_reviewer_order"),
+	}
+	if _, err := db.Collection("review").Indexes().CreateOne(ctx, reviewsByReviewer); err != nil {
+		return err
+	}
+
+	roleName := mongo.IndexModel{
+		Keys:    bson.D{{Key: "name", Value: 1}},
+		Options: options.Index().SetName("role_name").SetUnique(true),
+	}
+	if _, err := db.Collection("role").Indexes().CreateOne(ctx, roleName); err != nil {
+		return err
+	}
+
+	apiKeyId := mongo.IndexModel{
+		Keys:    bson.D{{Key: "key_id", Value: 1}},
+		Options: options.Index().SetName("api_key_key_id").SetUnique(true),
+	}
+	if _, err := db.Collection("api_key").Indexes().CreateOne(ctx, apiKeyId); err != nil {
+		return err
+	}
+
+	signingKeyKid := mongo.IndexModel{
+		Keys:    bson.D{{Key: "kid", Value: 1}},
+		Options: options.Index().SetName("signing_key_kid").SetUnique(true),
+	}
+	if _, err := db.Collection("signing_key").Indexes().CreateOne(ctx, signingKeyKid); err != nil {
+		return err
+	}
+
+	resetToken := mongo.IndexModel{
+		Keys:    bson.D{{Key: "reset_token_hash", Value: 1}},
+		Options: options.Index().SetName("user_reset_token").SetSparse(true),
+	}
+	if _, err := db.Collection("user").Indexes().CreateOne(ctx, resetToken); err != nil {
+		return err
+	}
+
+	verifyToken := mongo.IndexModel{
+		Keys:    bson.D{{Key: "verify_token_hash", Value: 1}},
+		Options: options.Index().SetName("user_verify_token").SetSparse(true),
+	}
+	if _, err := db.Collection("user").Indexes().CreateOne(ctx, verifyToken); err != nil {
+		return err
+	}
+
+	sessionFamily := mongo.IndexModel{
+		Keys:    bson.D{{Key: "sessions.family", Value: 1}},
+		Options: options.Index().SetName("user_session_family").SetSparse(true),
+	}
+	if _, err := db.Collection("user").Indexes().CreateOne(ctx, sessionFamily); err != nil {
+		return err
+	}
+
+	//one review per user per movie, Migrate drops older duplicates first
+	reviewPerUser := mongo.IndexModel{
+		Keys:    bson.D{{Key: "movie_id", Value: 1}, {Key: "reviewer_id", Value: 1}},
+		Options: options.Index().SetName("review_movie_reviewer").SetUnique(true),
+	}
+	_, err := db.Collection("review").Indexes().CreateOne(ctx, reviewPerUser)
+	return err
+}
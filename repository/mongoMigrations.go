@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/genesdemon/golang-jwt-project/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Migrate brings documents written by older versions up to the current
+// models. Every step only touches documents still in the old shape, so it is
+// safe to run on every start.
+func Migrate(ctx context.Context, db *mongo.Database) error {
+	if err := migrateMovieGenres(ctx, db.Collection("movie")); err != nil {
+		return err
+	}
+	if err := migrateEmailVerified(ctx, db.Collection("user")); err != nil {
+		return err
+	}
+	if err := migrateRefreshSessions(ctx, db.Collection("user")); err != nil {
+		return err
+	}
+	return migrateDuplicateReviews(ctx, db.Collection("review"), db.Collection("movie"))
+}
+
+// migrateMovieGenres turns the single genre_id of a movie into the genre_ids
+// list.
+func migrateMovieGenres(ctx context.Context, movies *mongo.Collection) error {
+	toList := bson.D{{Key: "$set", Value: bson.M{"genre_ids": bson.M{"$cond": bson.A{
+		bson.M{"$eq": bson.A{bson.M{"$type": "$genre_id"}, "string"}},
+		bson.A{"$genre_id"},
+		bson.A{},
+	}}}}}
+	dropOld := bson.D{{Key: "$unset", Value: "genre_id"}}
+	_, err := movies.UpdateMany(ctx, bson.M{"genre_id": bson.M{"$exists": true}}, mongo.Pipeline{toList, dropOld})
+	return err
+}
+
+// migrateEmailVerified treats accounts created before emails were verified as
+// verified, so turning on the review policy does not lock them out.
+func migrateEmailVerified(ctx context.Context, users *mongo.Collection) error {
+	_, err := users.UpdateMany(ctx, bson.M{"email_verified": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"email_verified": true}})
+	return err
+}
+
+// migrateRefreshSessions turns the single refresh_token and refresh_family of
+// a user into their first session, so signed in users stay signed in.
+func migrateRefreshSessions(ctx context.Context, users *mongo.Collection) error {
+	toSession := bson.D{{Key: "$set", Value: bson.M{"sessions": bson.M{"$cond": bson.A{
+		bson.M{"$eq": bson.A{bson.M{"$type": "$refresh_family"}, "string"}},
+		bson.A{bson.M{"family": "$refresh_family", "refresh_token": "$refresh_token", "created_at": "$updated_at"}},
+		bson.A{},
+	}}}}}
+	dropOld := bson.D{{Key: "$unset", Value: bson.A{"refresh_token", "refresh_family"}}}
+	_, err := users.UpdateMany(ctx, bson.M{"refresh_family": bson.M{"$exists": true}}, mongo.Pipeline{toSession, dropOld})
+	return err
+}
+
+// migrateDuplicateReviews keeps only the newest review of each reviewer for a
+// movie, so the unique review_movie_reviewer index can be built, and
+// recomputes the ratings of the movies that lost reviews.
+func migrateDuplicateReviews(ctx context.Context, reviews *mongo.Collection, movies *mongo.Collection) error {
+	cursor, err := reviews.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$sort", Value: bson.D{{Key: "updated_at", Value: -1}, {Key: "_id", Value: -1}}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: bson.D{{Key: "movie_id", Value: "$movie_id"}, {Key: "reviewer_id", Value: "$reviewer_id"}}},
+			{Key: "movie_id", Value: bson.M{"$first": "$movie_id"}},
+			{Key: "ids", Value: bson.M{"$push": "$_id"}},
+		}}},
+		{{Key: "$match", Value: bson.M{"ids.1": bson.M{"$exists": true}}}},
+	})
+	if err != nil {
+		return err
+	}
+	var duplicates []struct {
+		Movie_id *string              `bson:"movie_id"`
+		Ids      []primitive.ObjectID `bson:"ids"`
+	}
+	if err := cursor.All(ctx, &duplicates); err != nil {
+		return err
+	}
+
+	movieIds := map[string]bool{}
+	for _, group := range duplicates {
+		//the first id is the newest review, which is kept
+		if _, err := reviews.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": group.Ids[1:]}}); err != nil {
+			return err
+		}
+		if group.Movie_id != nil {
+			movieIds[*group.Movie_id] = true
+		}
+	}
+	for movieId := range movieIds {
+		if err := recomputeRatings(ctx, reviews, movies, movieId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recomputeRatings rebuilds the rating summary of a movie from its reviews.
+func recomputeRatings(ctx context.Context, reviews *mongo.Collection, movies *mongo.Collection, movieId string) error {
+	id, err := primitive.ObjectIDFromHex(movieId)
+	if err != nil {
+		//reviews of a movie id that cannot exist have no summary to fix
+		return nil
+	}
+	cursor, err := reviews.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"movie_id": movieId, "rating": bson.M{"$type": "number"}}}},
+		{{Key: "$group", Value: bson.D{{Key: "_id", Value: "$rating"}, {Key: "count", Value: bson.M{"$sum": 1}}}}},
+	})
+	if err != nil {
+		return err
+	}
+	var counts []struct {
+		Rating int `bson:"_id"`
+		Count  int `bson:"count"`
+	}
+	if err := cursor.All(ctx, &counts); err != nil {
+		return err
+	}
+
+	summary := models.RatingSummary{Histogram: map[string]int{}}
+	for _, c := range counts {
+		summary.Count += c.Count
+		summary.Sum += c.Count * c.Rating
+		summary.Histogram[strconv.Itoa(c.Rating)] = c.Count
+	}
+	if summary.Count > 0 {
+		summary.Average = float64(summary.Sum) / float64(summary.Count)
+	}
+	_, err = movies.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"ratings": summary}})
+	return err
+}
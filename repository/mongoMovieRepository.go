@@ -0,0 +1,200 @@
+package repository
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/genesdemon/golang-jwt-project/models"
+	"github.com/genesdemon/golang-jwt-project/pagination"
+	"github.com/genesdemon/golang-jwt-project/query"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoMovieRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoMovieRepository) Create(ctx context.Context, movie *models.Movie) error {
+	_, err := r.collection.InsertOne(ctx, movie)
+	return err
+}
+
+func (r *mongoMovieRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Movie, error) {
+	var movie models.Movie
+	if err := findOne(ctx, r.collection, bson.M{"_id": id}, &movie); err != nil {
+		return nil, err
+	}
+	return &movie, nil
+}
+
+func (r *mongoMovieRepository) NameExists(ctx context.Context, name string) (bool, error) {
+	count, err := r.collection.CountDocuments(ctx, bson.M{"name": equalFold(name)})
+	return count > 0, err
+}
+
+func (r *mongoMovieRepository) List(ctx context.Context, filter query.Filter, req pagination.Request) (*pagination.Page[models.Movie], error) {
+	return cursorPage[models.Movie](ctx, r.collection, bson.M{}, filter, req)
+}
+
+func (r *mongoMovieRepository) Update(ctx context.Context, id primitive.ObjectID, movie *models.Movie) (*models.Movie, error) {
+	update := bson.M{
+		"name":       movie.Name,
+		"topic":      movie.Topic,
+		"genre_ids":  movie.Genre_ids,
+		"movie_url":  movie.Movie_URL,
+		"updated_at": now()}
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": update})
+	if err != nil {
+		return nil, err
+	}
+	if result.MatchedCount < 1 {
+		return nil, ErrNotFound
+	}
+	return r.FindByID(ctx, id)
+}
+
+func (r *mongoMovieRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return deleteByID(ctx, r.collection, id)
+}
+
+// Search never loads more than three times limit movies. A movie ranked in
+// the first limit is among the best limit text matches, among the best limit
+// text matches in the genres, or, matching the genres only, among the first
+// limit movies of the genres by name, since everything ranked above it is too.
+func (r *mongoMovieRepository) Search(ctx context.Context, terms []string, genreIds []string, limit int) ([]ScoredMovie, error) {
+	scored := map[string]*ScoredMovie{}
+
+	if len(terms) > 0 {
+		text := bson.M{"$search": strings.Join(terms, " ")}
+		if err := r.textMatches(ctx, bson.M{"$text": text}, limit, scored); err != nil {
+			return nil, err
+		}
+		if len(genreIds) > 0 {
+			filter := bson.M{"$text": text, "genre_ids": bson.M{"$in": genreIds}}
+			if err := r.textMatches(ctx, filter, limit, scored); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if len(genreIds) > 0 {
+		var inGenres []models.Movie
+		//rankMovies breaks ties by name ignoring case, so does the collation
+		opts := options.Find().
+			SetSort(bson.D{{Key: "name", Value: 1}, {Key: "_id", Value: 1}}).
+			SetCollation(nameCollation).
+			SetLimit(int64(limit))
+		if err := findAll(ctx, r.collection, bson.M{"genre_ids": bson.M{"$in": genreIds}}, &inGenres, opts); err != nil {
+			return nil, err
+		}
+		for _, movie := range inGenres {
+			if _, ok := scored[movie.Id.Hex()]; !ok {
+				scored[movie.Id.Hex()] = &ScoredMovie{Movie: movie}
+			}
+		}
+	}
+
+	return rankMovies(scored, genreIds, limit), nil
+}
+
+// textMatches adds the best limit movies matching the $text filter to scored.
+func (r *mongoMovieRepository) textMatches(ctx context.Context, filter bson.M, limit int, scored map[string]*ScoredMovie) error {
+	var matches []struct {
+		models.Movie `bson:",inline"`
+		Score        float64 `bson:"score"`
+	}
+	textScore := bson.M{"$meta": "textScore"}
+	opts := options.Find().
+		SetProjection(bson.M{"score": textScore}).
+		SetSort(bson.M{"score": textScore}).
+		SetLimit(int64(limit))
+	if err := findAll(ctx, r.collection, filter, &matches, opts); err != nil {
+		return err
+	}
+	for _, match := range matches {
+		scored[match.Id.Hex()] = &ScoredMovie{Movie: match.Movie, Score: match.Score}
+	}
+	return nil
+}
+
+// nameCollation orders names ignoring case.
+var nameCollation = &options.Collation{Locale: "en", Strength: 2}
+
+func (r *mongoMovieRepository) FindByGenres(ctx context.Context, genreIds []string, matchAll bool) ([]models.Movie, error) {
+	movies := []models.Movie{}
+	err := findAll(ctx, r.collection, inGenres(genreIds, matchAll), &movies)
+	return movies, err
+}
+
+func (r *mongoMovieRepository) ListByGenres(ctx context.Context, genreIds []string, matchAll bool, filter query.Filter, req pagination.Request) (*pagination.Page[models.Movie], error) {
+	return cursorPage[models.Movie](ctx, r.collection, inGenres(genreIds, matchAll), filter, req)
+}
+
+func inGenres(genreIds []string, matchAll bool) bson.M {
+	operator := "$in"
+	if matchAll {
+		operator = "$all"
+	}
+	return bson.M{"genre_ids": bson.M{operator: genreIds}}
+}
+
+func (r *mongoMovieRepository) CountByGenre(ctx context.Context, genreId string) (int64, error) {
+	return r.collection.CountDocuments(ctx, bson.M{"genre_ids": genreId})
+}
+
+func (r *mongoMovieRepository) DeleteByGenre(ctx context.Context, genreId string) (int64, error) {
+	result, err := r.collection.DeleteMany(ctx, bson.M{"genre_ids": bson.A{genreId}})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+func (r *mongoMovieRepository) RemoveGenre(ctx context.Context, genreId string) (int64, error) {
+	update := bson.M{"$pull": bson.M{"genre_ids": genreId}, "$set": bson.M{"updated_at": now()}}
+	result, err := r.collection.UpdateMany(ctx, bson.M{"genre_ids": genreId}, update)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+func (r *mongoMovieRepository) ReassignGenre(ctx context.Context, fromGenreId string, toGenreId string) (int64, error) {
+	update := bson.M{"$set": bson.M{"genre_ids": bson.A{toGenreId}, "updated_at": now()}}
+	result, err := r.collection.UpdateMany(ctx, bson.M{"genre_ids": bson.A{fromGenreId}}, update)
+	if err != nil {
+		return 0, err
+	}
+	untagged, err := r.RemoveGenre(ctx, fromGenreId)
+	return result.ModifiedCount + untagged, err
+}
+
+func (r *mongoMovieRepository) ApplyRating(ctx context.Context, id primitive.ObjectID, removed *int, added *int) error {
+	inc := map[string]int{}
+	for _, change := range ratingChanges(removed, added) {
+		inc["ratings.count"] += change.delta
+		inc["ratings.sum"] += change.delta * change.rating
+		inc["ratings.histogram."+strconv.Itoa(change.rating)] += change.delta
+	}
+	filter := bson.M{"_id": id}
+	result, err := r.collection.UpdateOne(ctx, filter, bson.M{"$inc": inc})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount < 1 {
+		return ErrNotFound
+	}
+
+	//recompute the average from the stored totals
+	average := bson.D{{Key: "$set", Value: bson.M{"ratings.average": bson.M{"$cond": bson.A{
+		bson.M{"$gt": bson.A{"$ratings.count", 0}},
+		bson.M{"$divide": bson.A{"$ratings.sum", "$ratings.count"}},
+		0,
+	}}}}}
+	_, err = r.collection.UpdateOne(ctx, filter, mongo.Pipeline{average})
+	return err
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/genesdemon/golang-jwt-project/models"
+	"github.com/genesdemon/golang-jwt-project/pagination"
+	"github.com/genesdemon/golang-jwt-project/query"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type mongoReviewRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoReviewRepository) Create(ctx context.Context, review *models.Reviews) error {
+	_, err := r.collection.InsertOne(ctx, review)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+func (r *mongoReviewRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Reviews, error) {
+	var review models.Reviews
+	if err := findOne(ctx, r.collection, bson.M{"_id": id}, &review); err != nil {
+		return nil, err
+	}
+	return &review, nil
+}
+
+func (r *mongoReviewRepository) Update(ctx context.Context, id primitive.ObjectID, edit *models.ReviewEdit, previous models.ReviewRevision) (*models.Reviews, error) {
+	update := bson.M{
+		"$set": bson.M{
+			"review":     edit.Review,
+			"rating":     edit.Rating,
+			"updated_at": previous.Edited_at,
+		},
+		"$push": bson.M{"history": previous},
+	}
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, update)
+	if err != nil {
+		return nil, err
+	}
+	if result.MatchedCount < 1 {
+		return nil, ErrNotFound
+	}
+	return r.FindByID(ctx, id)
+}
+
+func (r *mongoReviewRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return deleteByID(ctx, r.collection, id)
+}
+
+func (r *mongoReviewRepository) FindByMovie(ctx context.Context, movieId string, filter query.Filter, req pagination.Request) (*pagination.Page[models.Reviews], error) {
+	return cursorPage[models.Reviews](ctx, r.collection, bson.M{"movie_id": movieId}, filter, req)
+}
+
+func (r *mongoReviewRepository) FindByReviewer(ctx context.Context, reviewerId string, filter query.Filter, req pagination.Request) (*pagination.Page[models.Reviews], error) {
+	return cursorPage[models.Reviews](ctx, r.collection, bson.M{"reviewer_id": reviewerId}, filter, req)
+}
+
+func (r *mongoReviewRepository) CountByMovie(ctx context.Context, movieId string) (int64, error) {
+	return r.collection.CountDocuments(ctx, bson.M{"movie_id": movieId})
+}
+
+func (r *mongoReviewRepository) DeleteByMovie(ctx context.Context, movieId string) (int64, error) {
+	result, err := r.collection.DeleteMany(ctx, bson.M{"movie_id": movieId})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/genesdemon/golang-jwt-project/models"
+	"github.com/genesdemon/golang-jwt-project/pagination"
+	"github.com/genesdemon/golang-jwt-project/query"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type mongoRoleRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoRoleRepository) Create(ctx context.Context, role *models.Role) error {
+	_, err := r.collection.InsertOne(ctx, role)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+func (r *mongoRoleRepository) FindByName(ctx context.Context, name string) (*models.Role, error) {
+	var role models.Role
+	if err := findOne(ctx, r.collection, bson.M{"name": name}, &role); err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+func (r *mongoRoleRepository) FindByNames(ctx context.Context, names []string) ([]models.Role, error) {
+	roles := []models.Role{}
+	if len(names) == 0 {
+		return roles, nil
+	}
+	err := findAll(ctx, r.collection, bson.M{"name": bson.M{"$in": names}}, &roles)
+	return roles, err
+}
+
+func (r *mongoRoleRepository) List(ctx context.Context, filter query.Filter, req pagination.Request) (*pagination.Page[models.Role], error) {
+	return cursorPage[models.Role](ctx, r.collection, bson.M{}, filter, req)
+}
+
+func (r *mongoRoleRepository) SetPermissions(ctx context.Context, name string, permissions []string) (*models.Role, error) {
+	update := bson.M{"$set": bson.M{"permissions": permissions, "updated_at": now()}}
+	result, err := r.collection.UpdateOne(ctx, bson.M{"name": name}, update)
+	if err != nil {
+		return nil, err
+	}
+	if result.MatchedCount < 1 {
+		return nil, ErrNotFound
+	}
+	return r.FindByName(ctx, name)
+}
@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/genesdemon/golang-jwt-project/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoSigningKeyRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoSigningKeyRepository) Create(ctx context.Context, key *models.SigningKey) error {
+	_, err := r.collection.InsertOne(ctx, key)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+func (r *mongoSigningKeyRepository) ListUnexpired(ctx context.Context, at time.Time) ([]models.SigningKey, error) {
+	keys := []models.SigningKey{}
+	opts := options.Find().SetSort(bson.D{{Key: "activates_at", Value: 1}, {Key: "_id", Value: 1}})
+	err := findAll(ctx, r.collection, bson.M{"expires_at": bson.M{"$gt": at}}, &keys, opts)
+	return keys, err
+}
+
+func (r *mongoSigningKeyRepository) SetPrivateKey(ctx context.Context, kid string, privateKey []byte, encryption string) error {
+	update := bson.M{"$set": bson.M{"private_key": privateKey, "private_key_encryption": encryption}}
+	result, err := r.collection.UpdateOne(ctx, bson.M{"kid": kid}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount < 1 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoSigningKeyRepository) DeleteExpired(ctx context.Context, at time.Time) (int64, error) {
+	result, err := r.collection.DeleteMany(ctx, bson.M{"expires_at": bson.M{"$lte": at}})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
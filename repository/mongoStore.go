@@ -0,0 +1,177 @@
+package repository
+
+import (
+	"context"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/genesdemon/golang-jwt-project/pagination"
+	"github.com/genesdemon/golang-jwt-project/query"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// NewMongoStore builds a Store backed by the collections of the given database.
+func NewMongoStore(db *mongo.Database) *Store {
+	return &Store{
+		Users:        &mongoUserRepository{collection: db.Collection("user")},
+		Roles:        &mongoRoleRepository{collection: db.Collection("role")},
+		APIKeys:      &mongoAPIKeyRepository{collection: db.Collection("api_key")},
+		SigningKeys:  &mongoSigningKeyRepository{collection: db.Collection("signing_key")},
+		Genres:       &mongoGenreRepository{collection: db.Collection("genre")},
+		Movies:       &mongoMovieRepository{collection: db.Collection("movie")},
+		Reviews:      &mongoReviewRepository{collection: db.Collection("review")},
+		Transactions: &mongoTransactor{client: db.Client()},
+	}
+}
+
+// mongoTransactor runs transactions in a session of the client, so the server
+// must be a replica set or a sharded cluster, as Atlas clusters are.
+type mongoTransactor struct {
+	client *mongo.Client
+}
+
+func (t *mongoTransactor) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	session, err := t.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+	//the driver retries fn on transient errors, each time from the start
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+	return err
+}
+
+// equalFold matches a string field exactly, ignoring case.
+func equalFold(value string) primitive.Regex {
+	return primitive.Regex{Pattern: "^" + regexp.QuoteMeta(value) + "$", Options: "i"}
+}
+
+func findOne(ctx context.Context, collection *mongo.Collection, filter interface{}, out interface{}, opts ...*options.FindOneOptions) error {
+	err := collection.FindOne(ctx, filter, opts...).Decode(out)
+	if err == mongo.ErrNoDocuments {
+		return ErrNotFound
+	}
+	return err
+}
+
+// projection reads only the fields of the document type of v, named the way
+// the driver names them: the bson tag, or else the lower cased field name.
+func projection(v interface{}) bson.D {
+	fields := bson.D{}
+	t := reflect.TypeOf(v)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("bson"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		fields = append(fields, bson.E{Key: name, Value: 1})
+	}
+	return fields
+}
+
+func findAll(ctx context.Context, collection *mongo.Collection, filter interface{}, out interface{}, opts ...*options.FindOptions) error {
+	cursor, err := collection.Find(ctx, filter, opts...)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	return cursor.All(ctx, out)
+}
+
+// cursorPage loads the page of documents matching base and filter that req
+// asks for, in req.Sort order with _id breaking ties. opts can add to the
+// query, such as a projection; the sort and limit are always cursorPage's.
+func cursorPage[T any](ctx context.Context, collection *mongo.Collection, base bson.M, filter query.Filter, req pagination.Request, opts ...*options.FindOptions) (*pagination.Page[T], error) {
+	backward := req.Cursor != nil && req.Cursor.Backward
+	ascending := func(desc bool) bool { return desc == backward }
+
+	order := bson.D{}
+	for _, f := range req.Sort {
+		order = append(order, bson.E{Key: f.Key, Value: direction(ascending(f.Desc))})
+	}
+	order = append(order, bson.E{Key: "_id", Value: direction(ascending(false))})
+
+	matching := bson.M{"$and": bson.A{base, filter.Mongo()}}
+	conditions := bson.A{base, filter.Mongo()}
+	if req.Cursor != nil {
+		conditions = append(conditions, afterCursor(req, ascending))
+	}
+
+	fetched := []T{}
+	opts = append(opts, options.Find().SetSort(order).SetLimit(int64(req.Limit+1)))
+	if err := findAll(ctx, collection, bson.M{"$and": conditions}, &fetched, opts...); err != nil {
+		return nil, err
+	}
+	result, err := pagination.Build(req, fetched)
+	if err != nil {
+		return nil, err
+	}
+	if req.WithTotal {
+		total, err := collection.CountDocuments(ctx, matching)
+		if err != nil {
+			return nil, err
+		}
+		result.Total = &total
+	}
+	return result, nil
+}
+
+// afterCursor matches the documents past the cursor in the fetch order: those
+// sharing the first i sort values with it and coming after it on the next.
+func afterCursor(req pagination.Request, ascending func(desc bool) bool) bson.M {
+	keys := make([]string, 0, len(req.Sort)+1)
+	after := make([]string, 0, len(req.Sort)+1)
+	for _, f := range req.Sort {
+		keys = append(keys, f.Key)
+		after = append(after, comparison(ascending(f.Desc)))
+	}
+	keys = append(keys, "_id")
+	after = append(after, comparison(ascending(false)))
+	values := append(append([]interface{}{}, req.Cursor.Values...), req.Cursor.Id)
+
+	anyOf := bson.A{}
+	for i := range keys {
+		clause := bson.M{}
+		for j := 0; j < i; j++ {
+			clause[keys[j]] = values[j]
+		}
+		clause[keys[i]] = bson.M{after[i]: values[i]}
+		anyOf = append(anyOf, clause)
+	}
+	return bson.M{"$or": anyOf}
+}
+
+func direction(ascending bool) int {
+	if ascending {
+		return 1
+	}
+	return -1
+}
+
+func comparison(ascending bool) string {
+	if ascending {
+		return "$gt"
+	}
+	return "$lt"
+}
+
+func deleteByID(ctx context.Context, collection *mongo.Collection, id primitive.ObjectID) error {
+	result, err := collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount < 1 {
+		return ErrNotFound
+	}
+	return nil
+}
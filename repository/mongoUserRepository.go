@@ -0,0 +1,359 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/genesdemon/golang-jwt-project/models"
+	"github.com/genesdemon/golang-jwt-project/pagination"
+	"github.com/genesdemon/golang-jwt-project/query"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoUserRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoUserRepository) Create(ctx context.Context, user *models.User) error {
+	_, err := r.collection.InsertOne(ctx, user)
+	return err
+}
+
+func (r *mongoUserRepository) FindByUserID(ctx context.Context, userId string) (*models.User, error) {
+	var user models.User
+	if err := findOne(ctx, r.collection, bson.M{"user_id": userId}, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *mongoUserRepository) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	var user models.User
+	if err := findOne(ctx, r.collection, bson.M{"email": email}, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *mongoUserRepository) FindByRefreshFamily(ctx context.Context, family string) (*models.User, error) {
+	var user models.User
+	if err := findOne(ctx, r.collection, bson.M{"sessions.family": family}, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *mongoUserRepository) FindPrivate(ctx context.Context, userId string) (*models.PrivateUser, error) {
+	var user models.PrivateUser
+	opts := options.FindOne().SetProjection(projection(user))
+	if err := findOne(ctx, r.collection, bson.M{"user_id": userId}, &user, opts); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *mongoUserRepository) FindPublic(ctx context.Context, userId string) (*models.PublicUser, error) {
+	var user models.PublicUser
+	opts := options.FindOne().SetProjection(projection(user))
+	if err := findOne(ctx, r.collection, bson.M{"user_id": userId}, &user, opts); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *mongoUserRepository) EmailExists(ctx context.Context, email string) (bool, error) {
+	count, err := r.collection.CountDocuments(ctx, bson.M{"email": equalFold(email)})
+	return count > 0, err
+}
+
+func (r *mongoUserRepository) UsernameExists(ctx context.Context, username string) (bool, error) {
+	count, err := r.collection.CountDocuments(ctx, bson.M{"username": equalFold(username)})
+	return count > 0, err
+}
+
+func (r *mongoUserRepository) List(ctx context.Context, filter query.Filter, req pagination.Request) (*pagination.Page[models.PrivateUser], error) {
+	opts := options.Find().SetProjection(projection(models.PrivateUser{}))
+	return cursorPage[models.PrivateUser](ctx, r.collection, bson.M{}, filter, req, opts)
+}
+
+func (r *mongoUserRepository) UpdateProfile(ctx context.Context, userId string, update models.UserUpdate) (*models.PrivateUser, error) {
+	set := bson.M{"updated_at": now()}
+	changes := bson.M{"$set": set}
+	if update.Name != nil {
+		set["name"] = *update.Name
+	}
+	if update.Username != nil {
+		set["username"] = *update.Username
+	}
+	if update.Email != nil {
+		set["email"] = *update.Email
+		set["email_verified"] = false
+		changes["$unset"] = bson.M{"verify_token_hash": "", "verify_expires_at": ""}
+	}
+	if err := r.updateOne(ctx, bson.M{"user_id": userId}, changes); err != nil {
+		return nil, err
+	}
+	return r.FindPrivate(ctx, userId)
+}
+
+func (r *mongoUserRepository) UpdateTokens(ctx context.Context, userId string, token string, refreshToken string, family string) error {
+	session := models.Session{Family: family, Refresh_token: refreshToken, Created_at: now()}
+	update := bson.D{
+		{Key: "$set", Value: bson.D{{Key: "token", Value: token}, {Key: "updated_at", Value: now()}}},
+		{Key: "$push", Value: bson.M{"sessions": bson.M{"$each": bson.A{session}, "$slice": -maxSessions}}},
+	}
+	_, err := r.collection.UpdateOne(ctx, bson.M{"user_id": userId}, update)
+	return err
+}
+
+func (r *mongoUserRepository) RotateTokens(ctx context.Context, userId string, presentedRefreshToken string, token string, refreshToken string) (bool, error) {
+	filter := bson.M{"user_id": userId, "sessions.refresh_token": presentedRefreshToken}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "token", Value: token},
+		{Key: "sessions.$.refresh_token", Value: refreshToken},
+		{Key: "updated_at", Value: now()},
+	}}}
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount == 1, nil
+}
+
+func (r *mongoUserRepository) RevokeFamily(ctx context.Context, family string) error {
+	_, err := r.collection.UpdateOne(ctx, bson.M{"sessions.family": family}, bson.D{
+		{Key: "$pull", Value: bson.M{"sessions": bson.M{"family": family}}},
+		{Key: "$set", Value: bson.D{{Key: "updated_at", Value: now()}}},
+	})
+	return err
+}
+
+func (r *mongoUserRepository) RevokeAll(ctx context.Context, userId string) error {
+	result, err := r.collection.UpdateOne(ctx, bson.M{"user_id": userId}, bson.D{
+		{Key: "$inc", Value: bson.D{{Key: "token_version", Value: 1}}},
+		{Key: "$unset", Value: unsetTokens},
+		{Key: "$set", Value: bson.D{{Key: "updated_at", Value: now()}}},
+	})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount < 1 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoUserRepository) SetResetToken(ctx context.Context, userId string, hash string, expiresAt time.Time) error {
+	update := bson.M{"$set": bson.M{"reset_token_hash": hash, "reset_expires_at": expiresAt}}
+	result, err := r.collection.UpdateOne(ctx, bson.M{"user_id": userId}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount < 1 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoUserRepository) ConsumeResetToken(ctx context.Context, hash string, now time.Time) (*models.User, error) {
+	filter := bson.M{"reset_token_hash": hash, "reset_expires_at": bson.M{"$gt": now}}
+	update := bson.M{"$unset": bson.M{"reset_token_hash": "", "reset_expires_at": ""}}
+	var user models.User
+	err := r.collection.FindOneAndUpdate(ctx, filter, update).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *mongoUserRepository) SetVerifyToken(ctx context.Context, userId string, hash string, expiresAt time.Time) error {
+	update := bson.M{"$set": bson.M{"verify_token_hash": hash, "verify_expires_at": expiresAt}}
+	result, err := r.collection.UpdateOne(ctx, bson.M{"user_id": userId}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount < 1 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoUserRepository) ConsumeVerifyToken(ctx context.Context, hash string, now time.Time) (*models.User, error) {
+	filter := bson.M{"verify_token_hash": hash, "verify_expires_at": bson.M{"$gt": now}}
+	update := bson.M{
+		"$unset": bson.M{"verify_token_hash": "", "verify_expires_at": ""},
+		"$set":   bson.M{"email_verified": true, "updated_at": now},
+	}
+	var user models.User
+	err := r.collection.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *mongoUserRepository) RecordFailedLogin(ctx context.Context, userId string, now time.Time, window time.Duration) (int, error) {
+	//a pipeline update so forgetting old failures and counting this one is atomic
+	recent := bson.M{"$gt": bson.A{"$last_failed_login", now.Add(-window)}}
+	count := bson.M{"$cond": bson.A{recent, bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$failed_logins", 0}}, 1}}, 1}}
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{"failed_logins": count, "last_failed_login": now}}}}
+	var user models.User
+	err := r.collection.FindOneAndUpdate(ctx, bson.M{"user_id": userId}, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	return user.Failed_logins, nil
+}
+
+func (r *mongoUserRepository) LockUntil(ctx context.Context, userId string, until time.Time) error {
+	result, err := r.collection.UpdateOne(ctx, bson.M{"user_id": userId}, bson.M{"$set": bson.M{"locked_until": until}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount < 1 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoUserRepository) ClearFailedLogins(ctx context.Context, userId string) (*models.PrivateUser, error) {
+	update := bson.M{
+		"$unset": bson.M{"failed_logins": "", "last_failed_login": "", "locked_until": ""},
+		"$set":   bson.M{"updated_at": now()},
+	}
+	result, err := r.collection.UpdateOne(ctx, bson.M{"user_id": userId}, update)
+	if err != nil {
+		return nil, err
+	}
+	if result.MatchedCount < 1 {
+		return nil, ErrNotFound
+	}
+	return r.FindPrivate(ctx, userId)
+}
+
+func (r *mongoUserRepository) SetTOTPSecret(ctx context.Context, userId string, secret string) error {
+	filter := bson.M{"user_id": userId, "mfa_enabled": bson.M{"$ne": true}}
+	return r.updateOne(ctx, filter, bson.M{"$set": bson.M{"totp_secret": secret}})
+}
+
+func (r *mongoUserRepository) EnableTOTP(ctx context.Context, userId string, step int64, recoveryHashes []string) error {
+	update := bson.M{"$set": bson.M{
+		"mfa_enabled":    true,
+		"totp_last_step": step,
+		"recovery_codes": recoveryHashes,
+		"updated_at":     now(),
+	}}
+	filter := bson.M{"user_id": userId, "mfa_enabled": bson.M{"$ne": true}}
+	return r.updateOne(ctx, filter, update)
+}
+
+func (r *mongoUserRepository) UseTOTPStep(ctx context.Context, userId string, step int64) (bool, error) {
+	filter := bson.M{"user_id": userId, "$or": bson.A{
+		bson.M{"totp_last_step": bson.M{"$lt": step}},
+		bson.M{"totp_last_step": bson.M{"$exists": false}},
+	}}
+	result, err := r.collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"totp_last_step": step}})
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount == 1, nil
+}
+
+func (r *mongoUserRepository) UseRecoveryCode(ctx context.Context, userId string, hash string) (bool, error) {
+	filter := bson.M{"user_id": userId, "recovery_codes": hash}
+	result, err := r.collection.UpdateOne(ctx, filter, bson.M{"$pull": bson.M{"recovery_codes": hash}})
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount == 1, nil
+}
+
+func (r *mongoUserRepository) DisableTOTP(ctx context.Context, userId string) error {
+	update := bson.M{
+		"$unset": bson.M{"totp_secret": "", "totp_last_step": "", "recovery_codes": ""},
+		"$set":   bson.M{"mfa_enabled": false, "updated_at": now()},
+	}
+	return r.updateOne(ctx, bson.M{"user_id": userId}, update)
+}
+
+// updateOne applies update to the user matching filter, or returns
+// ErrNotFound if there is none.
+func (r *mongoUserRepository) updateOne(ctx context.Context, filter bson.M, update bson.M) error {
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount < 1 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoUserRepository) SetPassword(ctx context.Context, userId string, passwordHash string) error {
+	//a new password also ends a lockout, which is why it was reset
+	unset := append(bson.D{
+		{Key: "failed_logins", Value: ""},
+		{Key: "last_failed_login", Value: ""},
+		{Key: "locked_until", Value: ""},
+	}, unsetTokens...)
+	result, err := r.collection.UpdateOne(ctx, bson.M{"user_id": userId}, bson.D{
+		{Key: "$inc", Value: bson.D{{Key: "token_version", Value: 1}}},
+		{Key: "$unset", Value: unset},
+		{Key: "$set", Value: bson.D{{Key: "password", Value: passwordHash}, {Key: "updated_at", Value: now()}}},
+	})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount < 1 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoUserRepository) AddRole(ctx context.Context, userId string, role string) (*models.PrivateUser, error) {
+	return r.changeRoles(ctx, userId, bson.M{"$addToSet": bson.M{"roles": role}})
+}
+
+func (r *mongoUserRepository) RemoveRole(ctx context.Context, userId string, role string) (*models.PrivateUser, error) {
+	return r.changeRoles(ctx, userId, bson.M{"$pull": bson.M{"roles": role}})
+}
+
+func (r *mongoUserRepository) SetUserType(ctx context.Context, userId string, userType string, revoke []string) (*models.PrivateUser, error) {
+	update := bson.M{}
+	if len(revoke) > 0 {
+		update["$pull"] = bson.M{"roles": bson.M{"$in": revoke}}
+	}
+	return r.changeRoles(ctx, userId, update, bson.E{Key: "user_type", Value: userType})
+}
+
+func (r *mongoUserRepository) changeRoles(ctx context.Context, userId string, update bson.M, set ...bson.E) (*models.PrivateUser, error) {
+	fields := bson.M{"updated_at": now()}
+	for _, field := range set {
+		fields[field.Key] = field.Value
+	}
+	update["$set"] = fields
+	result, err := r.collection.UpdateOne(ctx, bson.M{"user_id": userId}, update)
+	if err != nil {
+		return nil, err
+	}
+	if result.MatchedCount < 1 {
+		return nil, ErrNotFound
+	}
+	return r.FindPrivate(ctx, userId)
+}
+
+var unsetTokens = bson.D{
+	{Key: "token", Value: ""},
+	{Key: "sessions", Value: ""},
+}
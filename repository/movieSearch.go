@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/genesdemon/golang-jwt-project/search"
+)
+
+// rankMovies adds the genre bonus, orders by score (then name) and trims the
+// result to limit. Both stores finish a search with it.
+func rankMovies(scored map[string]*ScoredMovie, genreIds []string, limit int) []ScoredMovie {
+	inGenre := map[string]bool{}
+	for _, id := range genreIds {
+		inGenre[id] = true
+	}
+
+	results := make([]ScoredMovie, 0, len(scored))
+	for _, result := range scored {
+		if inAnyGenre(result.Movie.Genre_ids, inGenre) {
+			result.Score += search.GenreWeight
+		}
+		results = append(results, *result)
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return strings.ToLower(stringValue(results[i].Movie.Name)) < strings.ToLower(stringValue(results[j].Movie.Name))
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
@@ -0,0 +1,246 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/genesdemon/golang-jwt-project/models"
+	"github.com/genesdemon/golang-jwt-project/pagination"
+	"github.com/genesdemon/golang-jwt-project/query"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var ErrNotFound = errors.New("document not found")
+
+// maxSessions is how many devices a user can be signed in on at once. Signing
+// in on another one ends the oldest session.
+const maxSessions = 10
+
+// ErrDuplicate is returned when a write would break a uniqueness rule.
+var ErrDuplicate = errors.New("document already exists")
+
+type UserRepository interface {
+	Create(ctx context.Context, user *models.User) error
+	FindByUserID(ctx context.Context, userId string) (*models.User, error)
+	FindByEmail(ctx context.Context, email string) (*models.User, error)
+	FindByRefreshFamily(ctx context.Context, family string) (*models.User, error)
+	// FindPrivate and FindPublic load only the fields of the user that their
+	// view holds, so secrets are never read for a response.
+	FindPrivate(ctx context.Context, userId string) (*models.PrivateUser, error)
+	FindPublic(ctx context.Context, userId string) (*models.PublicUser, error)
+	EmailExists(ctx context.Context, email string) (bool, error)
+	UsernameExists(ctx context.Context, username string) (bool, error)
+	List(ctx context.Context, filter query.Filter, req pagination.Request) (*pagination.Page[models.PrivateUser], error)
+	// UpdateProfile changes the fields set in update and returns the user. A
+	// new email is not verified yet.
+	UpdateProfile(ctx context.Context, userId string, update models.UserUpdate) (*models.PrivateUser, error)
+
+	// UpdateTokens stores a freshly issued token pair as a new session of the
+	// user. Only the newest maxSessions sessions are kept.
+	UpdateTokens(ctx context.Context, userId string, token string, refreshToken string, family string) error
+	// RotateTokens replaces the refresh token of the session holding
+	// presentedRefreshToken. It reports false if no session holds it.
+	RotateTokens(ctx context.Context, userId string, presentedRefreshToken string, token string, refreshToken string) (bool, error)
+	// RevokeFamily ends the session of the family. Its access tokens carry the
+	// family, so they stop working too, while other sessions go on.
+	RevokeFamily(ctx context.Context, family string) error
+	// RevokeAll bumps the user's token version and drops the stored tokens.
+	RevokeAll(ctx context.Context, userId string) error
+	// AddRole and RemoveRole change the roles assigned to the user. Adding a
+	// role the user already has, or removing one they lack, is not an error.
+	AddRole(ctx context.Context, userId string, role string) (*models.PrivateUser, error)
+	RemoveRole(ctx context.Context, userId string, role string) (*models.PrivateUser, error)
+	// SetResetToken stores the hash of a new password reset token for the
+	// user, replacing any earlier one.
+	SetResetToken(ctx context.Context, userId string, hash string, expiresAt time.Time) error
+	// ConsumeResetToken clears the reset token with the given hash and
+	// returns its user, or ErrNotFound if no such token is unexpired at now.
+	// A token can therefore only be used once.
+	ConsumeResetToken(ctx context.Context, hash string, now time.Time) (*models.User, error)
+	// SetVerifyToken stores the hash of a new email verification token for
+	// the user, replacing any earlier one.
+	SetVerifyToken(ctx context.Context, userId string, hash string, expiresAt time.Time) error
+	// ConsumeVerifyToken clears the verification token with the given hash,
+	// marks the email of its user verified and returns the user, or
+	// ErrNotFound if no such token is unexpired at now.
+	ConsumeVerifyToken(ctx context.Context, hash string, now time.Time) (*models.User, error)
+	// RecordFailedLogin counts a wrong password for the user and returns how
+	// many happened in a row. Failures older than window are forgotten first.
+	RecordFailedLogin(ctx context.Context, userId string, now time.Time, window time.Duration) (int, error)
+	// LockUntil refuses sign in to the user until the given time.
+	LockUntil(ctx context.Context, userId string, until time.Time) error
+	// ClearFailedLogins forgets the user's failed logins and lifts any lockout.
+	ClearFailedLogins(ctx context.Context, userId string) (*models.PrivateUser, error)
+	// SetTOTPSecret stores a TOTP secret that is waiting to be confirmed,
+	// replacing any earlier one. It fails with ErrNotFound if the user has
+	// two-factor authentication enabled already.
+	SetTOTPSecret(ctx context.Context, userId string, secret string) error
+	// EnableTOTP turns two-factor authentication on once the stored secret is
+	// confirmed by a code of the given step, and stores the recovery code
+	// hashes. It fails with ErrNotFound if it is enabled already, so the
+	// recovery codes handed out first are never replaced.
+	EnableTOTP(ctx context.Context, userId string, step int64, recoveryHashes []string) error
+	// UseTOTPStep records that a code of the given step was used and reports
+	// false if that step or a later one was used before, so a code only works
+	// once.
+	UseTOTPStep(ctx context.Context, userId string, step int64) (bool, error)
+	// UseRecoveryCode removes the recovery code with the given hash and
+	// reports whether the user had it.
+	UseRecoveryCode(ctx context.Context, userId string, hash string) (bool, error)
+	// DisableTOTP turns two-factor authentication off and forgets the secret
+	// and the recovery codes.
+	DisableTOTP(ctx context.Context, userId string) error
+	// SetPassword stores a new password hash, revokes every session like
+	// RevokeAll and lifts any sign in lockout like ClearFailedLogins.
+	SetPassword(ctx context.Context, userId string, passwordHash string) error
+	// SetUserType changes the user's type and takes away any of the revoke
+	// roles the user was assigned.
+	SetUserType(ctx context.Context, userId string, userType string, revoke []string) (*models.PrivateUser, error)
+}
+
+type RoleRepository interface {
+	// Create stores a role, or returns ErrDuplicate if the name is taken.
+	Create(ctx context.Context, role *models.Role) error
+	FindByName(ctx context.Context, name string) (*models.Role, error)
+	// FindByNames returns the roles among names that exist.
+	FindByNames(ctx context.Context, names []string) ([]models.Role, error)
+	List(ctx context.Context, filter query.Filter, req pagination.Request) (*pagination.Page[models.Role], error)
+	SetPermissions(ctx context.Context, name string, permissions []string) (*models.Role, error)
+}
+
+type APIKeyRepository interface {
+	// Create stores a key, or returns ErrDuplicate if its key ID is taken.
+	Create(ctx context.Context, key *models.APIKey) error
+	FindByKeyID(ctx context.Context, keyId string) (*models.APIKey, error)
+	List(ctx context.Context, filter query.Filter, req pagination.Request) (*pagination.Page[models.APIKey], error)
+	// Revoke stops the key from authenticating. Revoking a revoked key
+	// changes nothing.
+	Revoke(ctx context.Context, keyId string) (*models.APIKey, error)
+	// Touch records when the key was last used.
+	Touch(ctx context.Context, keyId string, at time.Time) error
+}
+
+type SigningKeyRepository interface {
+	// Create stores a key, or returns ErrDuplicate if its kid is taken.
+	Create(ctx context.Context, key *models.SigningKey) error
+	// ListUnexpired returns the keys still valid at the given time, the
+	// earliest to activate first.
+	ListUnexpired(ctx context.Context, at time.Time) ([]models.SigningKey, error)
+	DeleteExpired(ctx context.Context, at time.Time) (int64, error)
+	// SetPrivateKey replaces the stored private key of the key with kid, to
+	// encrypt one that was stored in the clear.
+	SetPrivateKey(ctx context.Context, kid string, privateKey []byte, encryption string) error
+}
+
+type GenreRepository interface {
+	Create(ctx context.Context, genre *models.Genre) error
+	FindByID(ctx context.Context, id primitive.ObjectID) (*models.Genre, error)
+	// FindByIDs returns the genres among ids that exist, in no particular
+	// order.
+	FindByIDs(ctx context.Context, ids []primitive.ObjectID) ([]models.Genre, error)
+	// FindByName looks a genre up by its exact name, ignoring case.
+	FindByName(ctx context.Context, name string) (*models.Genre, error)
+	NameExists(ctx context.Context, name string) (bool, error)
+	List(ctx context.Context, filter query.Filter, req pagination.Request) (*pagination.Page[models.Genre], error)
+	Update(ctx context.Context, id primitive.ObjectID, name *string) (*models.Genre, error)
+	Delete(ctx context.Context, id primitive.ObjectID) error
+	// FindByNameTerms returns the genres whose name contains a word matching
+	// one of the search terms.
+	FindByNameTerms(ctx context.Context, terms []string) ([]models.Genre, error)
+}
+
+type MovieRepository interface {
+	Create(ctx context.Context, movie *models.Movie) error
+	FindByID(ctx context.Context, id primitive.ObjectID) (*models.Movie, error)
+	NameExists(ctx context.Context, name string) (bool, error)
+	List(ctx context.Context, filter query.Filter, req pagination.Request) (*pagination.Page[models.Movie], error)
+	Update(ctx context.Context, id primitive.ObjectID, movie *models.Movie) (*models.Movie, error)
+	Delete(ctx context.Context, id primitive.ObjectID) error
+	// Search ranks movies by how well their name and topic match the terms.
+	// Movies in one of genreIds also match and get a genre bonus.
+	Search(ctx context.Context, terms []string, genreIds []string, limit int) ([]ScoredMovie, error)
+	// FindByGenres returns the movies tagged with any of genreIds, or with all
+	// of them when matchAll is set.
+	FindByGenres(ctx context.Context, genreIds []string, matchAll bool) ([]models.Movie, error)
+	// ListByGenres returns one page of the movies FindByGenres matches.
+	ListByGenres(ctx context.Context, genreIds []string, matchAll bool, filter query.Filter, req pagination.Request) (*pagination.Page[models.Movie], error)
+	CountByGenre(ctx context.Context, genreId string) (int64, error)
+	// DeleteByGenre deletes the movies whose only genre is genreId.
+	DeleteByGenre(ctx context.Context, genreId string) (int64, error)
+	// RemoveGenre untags genreId from every movie tagged with it.
+	RemoveGenre(ctx context.Context, genreId string) (int64, error)
+	// ReassignGenre moves the movies whose only genre is fromGenreId to
+	// toGenreId and untags fromGenreId from the rest.
+	ReassignGenre(ctx context.Context, fromGenreId string, toGenreId string) (int64, error)
+	// ApplyRating removes the removed rating from the movie's rating summary
+	// and adds the added one in a single update. Either may be nil, so an edit
+	// replaces a rating at once while a new or deleted review passes one.
+	ApplyRating(ctx context.Context, id primitive.ObjectID, removed *int, added *int) error
+}
+
+type ReviewRepository interface {
+	// Create stores a review, failing with ErrDuplicate when the reviewer has
+	// already reviewed the movie.
+	Create(ctx context.Context, review *models.Reviews) error
+	FindByID(ctx context.Context, id primitive.ObjectID) (*models.Reviews, error)
+	// Update replaces the review text and rating and appends the previous
+	// version to the review's history.
+	Update(ctx context.Context, id primitive.ObjectID, edit *models.ReviewEdit, previous models.ReviewRevision) (*models.Reviews, error)
+	Delete(ctx context.Context, id primitive.ObjectID) error
+	FindByMovie(ctx context.Context, movieId string, filter query.Filter, req pagination.Request) (*pagination.Page[models.Reviews], error)
+	FindByReviewer(ctx context.Context, reviewerId string, filter query.Filter, req pagination.Request) (*pagination.Page[models.Reviews], error)
+	CountByMovie(ctx context.Context, movieId string) (int64, error)
+	DeleteByMovie(ctx context.Context, movieId string) (int64, error)
+}
+
+type ScoredMovie struct {
+	Movie models.Movie
+	Score float64
+}
+
+// Transactor runs fn so that the writes it makes through the repositories,
+// using the ctx it is handed, are applied together or not at all.
+type Transactor interface {
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// Store groups the repositories the controllers depend on.
+type Store struct {
+	Users   UserRepository
+	Roles   RoleRepository
+	APIKeys APIKeyRepository
+	// SigningKeys hold the keys of the asymmetric token algorithms.
+	SigningKeys SigningKeyRepository
+	Genres      GenreRepository
+	Movies      MovieRepository
+	Reviews     ReviewRepository
+	// Transactions group writes that span repositories, such as a review and
+	// the rating summary of its movie.
+	Transactions Transactor
+}
+
+// now returns the current time truncated to the second, the precision every
+// stored timestamp uses.
+func now() time.Time {
+	t, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	return t
+}
+
+type ratingChange struct {
+	rating int
+	delta  int
+}
+
+// ratingChanges lists what ApplyRating does to a rating summary: -1 for the
+// removed rating and +1 for the added one. Both stores apply it.
+func ratingChanges(removed *int, added *int) []ratingChange {
+	changes := []ratingChange{}
+	if removed != nil {
+		changes = append(changes, ratingChange{rating: *removed, delta: -1})
+	}
+	if added != nil {
+		changes = append(changes, ratingChange{rating: *added, delta: 1})
+	}
+	return changes
+}
@@ -0,0 +1,170 @@
+package response
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Code is the machine readable kind of an error.
+type Code string
+
+const (
+	CodeBadRequest       Code = "bad_request"
+	CodeValidationFailed Code = "validation_failed"
+	CodeUnauthorized     Code = "unauthorized"
+	CodeForbidden        Code = "forbidden"
+	CodeNotFound         Code = "not_found"
+	CodeConflict         Code = "conflict"
+	CodeTooManyRequests  Code = "too_many_requests"
+	CodeInternal         Code = "internal_error"
+)
+
+// FieldError describes one field that failed validation.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Error is an API error. Meta carries extra machine readable context, such as
+// the counts that made a delete conflict.
+type Error struct {
+	Status    int                    `json:"-"`
+	Code      Code                   `json:"code"`
+	Message   string                 `json:"message"`
+	Details   []FieldError           `json:"details,omitempty"`
+	Meta      map[string]interface{} `json:"meta,omitempty"`
+	RequestID string                 `json:"request_id"`
+	cause     error
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// With adds a value to the error's Meta.
+func (e *Error) With(key string, value interface{}) *Error {
+	if e.Meta == nil {
+		e.Meta = map[string]interface{}{}
+	}
+	e.Meta[key] = value
+	return e
+}
+
+func newError(status int, code Code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+func BadRequest(message string) *Error {
+	return newError(http.StatusBadRequest, CodeBadRequest, message)
+}
+
+func Unauthorized(message string) *Error {
+	return newError(http.StatusUnauthorized, CodeUnauthorized, message)
+}
+
+func Forbidden(message string) *Error {
+	return newError(http.StatusForbidden, CodeForbidden, message)
+}
+
+func NotFound(message string) *Error {
+	return newError(http.StatusNotFound, CodeNotFound, message)
+}
+
+func Conflict(message string) *Error {
+	return newError(http.StatusConflict, CodeConflict, message)
+}
+
+func TooManyRequests(message string) *Error {
+	return newError(http.StatusTooManyRequests, CodeTooManyRequests, message)
+}
+
+// Internal wraps an unexpected error. Its text is logged, not returned.
+func Internal(cause error) *Error {
+	err := newError(http.StatusInternalServerError, CodeInternal, "internal server error")
+	err.cause = cause
+	return err
+}
+
+// Invalid reports a request body that could not be used. Validation errors
+// from the validator library are broken down per field; anything else, such
+// as malformed JSON, is a plain bad request.
+func Invalid(err error) *Error {
+	var fieldErrs validator.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		return BadRequest(err.Error())
+	}
+	details := make([]FieldError, 0, len(fieldErrs))
+	for _, fe := range fieldErrs {
+		details = append(details, FieldError{
+			Field:   fieldPath(fe),
+			Rule:    fe.Tag(),
+			Message: ruleMessage(fe),
+		})
+	}
+	return ValidationFailed("request validation failed", details...)
+}
+
+// ValidationFailed reports input that was well formed but not acceptable,
+// field by field.
+func ValidationFailed(message string, details ...FieldError) *Error {
+	err := newError(http.StatusBadRequest, CodeValidationFailed, message)
+	err.Details = details
+	return err
+}
+
+// fieldPath drops the struct name from the validator's namespace, leaving
+// e.g. "genre_ids[0]".
+func fieldPath(fe validator.FieldError) string {
+	namespace := fe.Namespace()
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+	return namespace
+}
+
+func ruleMessage(fe validator.FieldError) string {
+	unit := ""
+	switch fe.Kind().String() {
+	case "string":
+		unit = " characters"
+	case "slice", "array", "map":
+		unit = " items"
+	}
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "min":
+		return fmt.Sprintf("must be at least %s%s", fe.Param(), unit)
+	case "max":
+		return fmt.Sprintf("must be at most %s%s", fe.Param(), unit)
+	case "len":
+		return fmt.Sprintf("must be exactly %s%s", fe.Param(), unit)
+	case "eq":
+		return "must be " + fe.Param()
+	case "oneof":
+		return "must be one of " + fe.Param()
+	}
+	//eq=A|eq=B is how the models spell an enum
+	if alternatives := strings.Split(fe.Tag(), "|"); len(alternatives) > 1 {
+		values := []string{}
+		for _, alternative := range alternatives {
+			if !strings.HasPrefix(alternative, "eq=") {
+				return "must satisfy " + fe.Tag()
+			}
+			values = append(values, strings.TrimPrefix(alternative, "eq="))
+		}
+		return "must be one of " + strings.Join(values, ", ")
+	}
+	return "failed the " + fe.Tag() + " rule"
+}
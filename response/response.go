@@ -0,0 +1,43 @@
+package response
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDKey is the gin context key the request ID middleware stores the ID
+// under.
+const RequestIDKey = "request_id"
+
+// envelope is the body of every API response. Successful responses carry
+// Data, failed ones Error.
+type envelope struct {
+	Status  int         `json:"Status"`
+	Message string      `json:"Message"`
+	Data    interface{} `json:"Data,omitempty"`
+	Error   *Error      `json:"Error,omitempty"`
+}
+
+// Success responds with payload under Data.data. Every successful response
+// goes through it, including list pages and responses that carry several
+// values, so clients always find the payload in the same place.
+func Success(c *gin.Context, status int, payload interface{}) {
+	c.JSON(status, envelope{Status: status, Message: "success", Data: gin.H{"data": payload}})
+}
+
+// Fail responds with err and stops the handler chain. Errors that are not an
+// *Error are logged and reported as internal errors, so their text never
+// reaches the client.
+func Fail(c *gin.Context, err error) {
+	apiErr, ok := err.(*Error)
+	if !ok {
+		apiErr = Internal(err)
+	}
+	apiErr.RequestID = c.GetString(RequestIDKey)
+	if apiErr.Status == http.StatusInternalServerError && apiErr.cause != nil {
+		log.Printf("request %s: %v", apiErr.RequestID, apiErr.cause)
+	}
+	c.AbortWithStatusJSON(apiErr.Status, envelope{Status: apiErr.Status, Message: "error", Error: apiErr})
+}
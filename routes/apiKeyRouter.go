@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"github.com/genesdemon/golang-jwt-project/controllers"
+	"github.com/genesdemon/golang-jwt-project/middleware"
+	"github.com/genesdemon/golang-jwt-project/rbac"
+	"github.com/genesdemon/golang-jwt-project/repository"
+)
+
+// APIKeyRoutes manage the keys service accounts use. Handing out a key is
+// handing out permissions, so it takes the same permission as managing roles.
+func APIKeyRoutes(groups Groups, store *repository.Store) {
+	incomingRoutes := groups.Authenticated.Group("", middleware.RequirePermission(rbac.RoleManage))
+	incomingRoutes.POST("/api-keys", controllers.CreateAPIKey(store.APIKeys))
+	incomingRoutes.GET("/api-keys", controllers.GetAPIKeys(store.APIKeys))
+	incomingRoutes.DELETE("/api-keys/:key_id", controllers.RevokeAPIKey(store.APIKeys))
+}
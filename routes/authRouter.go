@@ -2,10 +2,20 @@ package routes
 
 import (
 	controller "github.com/genesdemon/golang-jwt-project/controllers"
-	"github.com/gin-gonic/gin"
+	"github.com/genesdemon/golang-jwt-project/mailer"
+	"github.com/genesdemon/golang-jwt-project/repository"
 )
 
-func AuthRoutes(incomingRoutes *gin.Engine) {
-	incomingRoutes.POST("users/signup", controller.Signup())
-	incomingRoutes.POST("users/signin", controller.Login())
+func AuthRoutes(groups Groups, store *repository.Store, mail mailer.Mailer) {
+	groups.Public.POST("users/signup", controller.Signup(store.Users, mail))
+	groups.Public.POST("users/signin", controller.Login(store.Users))
+	groups.Public.POST("users/signin/mfa", controller.LoginMFA(store.Users))
+	groups.Public.POST("users/refresh", controller.Refresh(store.Users))
+	groups.Public.POST("users/password/forgot", controller.ForgotPassword(store.Users, mail))
+	groups.Public.POST("users/password/reset", controller.ResetPassword(store.Users))
+	groups.Public.GET("users/verify", controller.VerifyEmail(store.Users))
+	groups.Authenticated.POST("users/verify/resend", controller.ResendVerification(store.Users, mail))
+	groups.Authenticated.POST("users/me/mfa/totp", controller.EnrolTOTP(store.Users))
+	groups.Authenticated.POST("users/me/mfa/totp/confirm", controller.ConfirmTOTP(store.Users))
+	groups.Authenticated.POST("users/me/mfa/disable", controller.DisableTOTP(store.Users))
 }
@@ -3,15 +3,14 @@ package routes
 import (
 	"github.com/genesdemon/golang-jwt-project/controllers"
 	"github.com/genesdemon/golang-jwt-project/middleware"
-	"github.com/gin-gonic/gin"
+	"github.com/genesdemon/golang-jwt-project/rbac"
+	"github.com/genesdemon/golang-jwt-project/repository"
 )
 
-func GenreRoutes(incomingRoutes gin.Engine) {
-	incomingRoutes.Use(middleware.Authenticate())
-	incomingRoutes.POST("/genres/creategenre", controllers.CreateGenre())
-	incomingRoutes.GET("/genres/:genre_id", controllers.GetGenre())
-	incomingRoutes.GET("/genres/getgenres", controllers.GetGenres())
-	incomingRoutes.PUT("/genres/editgenre/:genre_id", controllers.EditGenre())
-	incomingRoutes.DELETE("/genres/:genre_id", controllers.DeleteAGenre())
-
+func GenreRoutes(groups Groups, store *repository.Store) {
+	groups.Authenticated.POST("/genres/creategenre", middleware.RequirePermission(rbac.GenreWrite), controllers.CreateGenre(store.Genres))
+	groups.Catalog.GET("/genres/:genre_id", controllers.GetGenre(store.Genres))
+	groups.Catalog.GET("/genres/getgenres", controllers.GetGenres(store.Genres))
+	groups.Authenticated.PUT("/genres/editgenre/:genre_id", middleware.RequirePermission(rbac.GenreWrite), controllers.EditGenre(store.Genres))
+	groups.Authenticated.DELETE("/genres/:genre_id", middleware.RequirePermission(rbac.GenreWrite), controllers.DeleteAGenre(store.Genres, store.Movies, store.Reviews))
 }
@@ -3,16 +3,17 @@ package routes
 import (
 	"github.com/genesdemon/golang-jwt-project/controllers"
 	"github.com/genesdemon/golang-jwt-project/middleware"
-	"github.com/gin-gonic/gin"
+	"github.com/genesdemon/golang-jwt-project/rbac"
+	"github.com/genesdemon/golang-jwt-project/repository"
 )
 
-func MovieRoutes(incomingRoutes gin.Engine) {
-	incomingRoutes.Use(middleware.Authenticate())
-	incomingRoutes.POST("/movies/createmovie", controllers.CreateMovie())
-	incomingRoutes.GET("/movies/:movie_id", controllers.GetMovie())
-	incomingRoutes.GET("/movies/getmovies", controllers.GetMovies())
-	incomingRoutes.PUT("/movies/editmovie/:movie_id", controllers.EditMovie())
-	incomingRoutes.DELETE("/movies/:movie_id", controllers.DeleteMovie())
-	incomingRoutes.GET("/movies/search", controllers.SearchMovieByQuery())
-	incomingRoutes.GET("/movies/filter", controllers.SearchMovieByGenre())
+func MovieRoutes(groups Groups, store *repository.Store) {
+	groups.Authenticated.POST("/movies/createmovie", middleware.RequirePermission(rbac.MovieWrite), controllers.CreateMovie(store.Movies, store.Genres))
+	groups.Catalog.GET("/movies/:movie_id", controllers.GetMovie(store.Movies, store.Genres))
+	groups.Catalog.GET("/movies/:movie_id/ratings", controllers.GetMovieRatings(store.Movies))
+	groups.Catalog.GET("/movies/getmovies", controllers.GetMovies(store.Movies, store.Genres))
+	groups.Authenticated.PUT("/movies/editmovie/:movie_id", middleware.RequirePermission(rbac.MovieWrite), controllers.EditMovie(store.Movies, store.Genres))
+	groups.Authenticated.DELETE("/movies/:movie_id", middleware.RequirePermission(rbac.MovieWrite), controllers.DeleteMovie(store.Movies, store.Reviews))
+	groups.Catalog.GET("/movies/search", controllers.SearchMovieByQuery(store.Movies, store.Genres))
+	groups.Catalog.GET("/movies/filter", controllers.SearchMovieByGenre(store.Movies, store.Genres))
 }
@@ -3,13 +3,14 @@ package routes
 import (
 	"github.com/genesdemon/golang-jwt-project/controllers"
 	"github.com/genesdemon/golang-jwt-project/middleware"
-	"github.com/gin-gonic/gin"
+	"github.com/genesdemon/golang-jwt-project/rbac"
+	"github.com/genesdemon/golang-jwt-project/repository"
 )
 
-func ReviewRoutes(incomingRoutes gin.Engine) {
-	incomingRoutes.Use(middleware.Authenticate())
-	incomingRoutes.POST("reviews/addreview", controllers.AddAReview())
-	incomingRoutes.DELETE("/reviews/:_id", controllers.DeleteAReview())
-	incomingRoutes.GET("/reviews/review_id", controllers.ViewAMovieReviews())
-	incomingRoutes.GET("/reviews/:reviewer_id", controllers.AllUserReviews())
+func ReviewRoutes(groups Groups, store *repository.Store) {
+	groups.Authenticated.POST("reviews/addreview", middleware.RequirePermission(rbac.ReviewWrite), controllers.AddAReview(store.Transactions, store.Reviews, store.Movies))
+	groups.Authenticated.PUT("/reviews/:_id", controllers.EditAReview(store.Transactions, store.Reviews, store.Movies))
+	groups.Authenticated.DELETE("/reviews/:_id", controllers.DeleteAReview(store.Transactions, store.Reviews, store.Movies))
+	groups.Catalog.GET("/reviews/review_id", controllers.ViewAMovieReviews(store.Reviews))
+	groups.Catalog.GET("/reviews/:reviewer_id", controllers.AllUserReviews(store.Reviews))
 }
@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"github.com/genesdemon/golang-jwt-project/controllers"
+	"github.com/genesdemon/golang-jwt-project/middleware"
+	"github.com/genesdemon/golang-jwt-project/rbac"
+	"github.com/genesdemon/golang-jwt-project/repository"
+)
+
+func RoleRoutes(groups Groups, store *repository.Store) {
+	incomingRoutes := groups.Authenticated.Group("", middleware.RequirePermission(rbac.RoleManage))
+	incomingRoutes.POST("/roles", controllers.CreateRole(store.Roles))
+	incomingRoutes.GET("/roles", controllers.GetRoles(store.Roles))
+	incomingRoutes.PUT("/roles/:role_name", controllers.UpdateRolePermissions(store.Roles))
+	incomingRoutes.POST("/users/:user_id/roles", controllers.AssignRole(store.Users, store.Roles))
+	incomingRoutes.DELETE("/users/:user_id/roles/:role_name", controllers.UnassignRole(store.Users))
+	incomingRoutes.PUT("/users/:user_id/user_type", controllers.SetUserType(store.Users))
+}
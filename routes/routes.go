@@ -0,0 +1,53 @@
+package routes
+
+import (
+	"github.com/genesdemon/golang-jwt-project/controllers"
+	"github.com/genesdemon/golang-jwt-project/mailer"
+	"github.com/genesdemon/golang-jwt-project/middleware"
+	"github.com/genesdemon/golang-jwt-project/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// APIVersion is the path prefix of the current version of the API.
+const APIVersion = "/api/v1"
+
+// Groups are the route groups each set of routes registers on. Routes only
+// get the middleware of the group they are put in, so whether a route needs
+// a token is decided here rather than by registration order.
+type Groups struct {
+	// Public routes can be called without a token.
+	Public *gin.RouterGroup
+	// Authenticated routes need a valid access token.
+	Authenticated *gin.RouterGroup
+	// Catalog is where reads of genres, movies and reviews go. It is Public
+	// when the catalog is configured public and Authenticated otherwise.
+	Catalog *gin.RouterGroup
+}
+
+// Register mounts every route under APIVersion, and again at the root for
+// clients of the unversioned paths, which answer with a Deprecation header.
+func Register(router *gin.Engine, store *repository.Store, mail mailer.Mailer, publicCatalog bool) {
+	//a well-known path, so it is not versioned
+	router.GET("/.well-known/jwks.json", controllers.JWKS())
+	mount(router.Group(APIVersion), store, mail, publicCatalog)
+	mount(router.Group("/", middleware.Deprecated(APIVersion)), store, mail, publicCatalog)
+}
+
+func mount(base *gin.RouterGroup, store *repository.Store, mail mailer.Mailer, publicCatalog bool) {
+	groups := Groups{
+		Public:        base.Group(""),
+		Authenticated: base.Group("", middleware.Authenticate(store.Users, store.Roles, store.APIKeys)),
+	}
+	groups.Catalog = groups.Authenticated
+	if publicCatalog {
+		groups.Catalog = groups.Public
+	}
+
+	AuthRoutes(groups, store, mail)
+	UserRoutes(groups, store, mail)
+	GenreRoutes(groups, store)
+	MovieRoutes(groups, store)
+	ReviewRoutes(groups, store)
+	RoleRoutes(groups, store)
+	APIKeyRoutes(groups, store)
+}
@@ -2,13 +2,19 @@ package routes
 
 import (
 	controller "github.com/genesdemon/golang-jwt-project/controllers"
+	"github.com/genesdemon/golang-jwt-project/mailer"
 	"github.com/genesdemon/golang-jwt-project/middleware"
-	"github.com/gin-gonic/gin"
+	"github.com/genesdemon/golang-jwt-project/rbac"
+	"github.com/genesdemon/golang-jwt-project/repository"
 )
 
-func UserRoutes(incomingRoutes gin.Engine) {
-	incomingRoutes.Use(middleware.Authenticate())
-	incomingRoutes.GET("/users", controller.GetUsers())
-	incomingRoutes.GET("/users/:user_id", controller.GetUser())
-	incomingRoutes.PUT("/users/edituser", controller.EditUser())
+func UserRoutes(groups Groups, store *repository.Store, mail mailer.Mailer) {
+	groups.Authenticated.GET("/users", middleware.RequirePermission(rbac.UserRead), controller.GetUsers(store.Users))
+	groups.Authenticated.GET("/users/:user_id", controller.GetUser(store.Users))
+	groups.Authenticated.PUT("/users/me", controller.UpdateMe(store.Users, mail))
+	groups.Authenticated.POST("/users/me/password", controller.ChangePassword(store.Users))
+	groups.Authenticated.PUT("/users/:user_id", middleware.RequirePermission(rbac.UserWrite), controller.UpdateUser(store.Users, mail))
+	groups.Authenticated.POST("/users/logout", controller.Logout(store.Users))
+	groups.Authenticated.POST("/users/:user_id/revoke", middleware.RequirePermission(rbac.UserWrite), controller.RevokeUserSessions(store.Users))
+	groups.Authenticated.POST("/users/:user_id/unlock", middleware.RequirePermission(rbac.UserWrite), controller.UnlockUser(store.Users))
 }
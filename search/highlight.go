@@ -0,0 +1,76 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	highlightOpen  = "<em>"
+	highlightClose = "</em>"
+	snippetRadius  = 60
+)
+
+// Highlight returns a snippet of text around the first match with every
+// matching word wrapped in <em> tags. It returns "" when nothing matches.
+func Highlight(text string, terms []string) string {
+	runes := []rune(text)
+
+	type span struct{ start, end int }
+	var spans []span
+	for i := 0; i < len(runes); {
+		if !isWordRune(runes[i]) {
+			i++
+			continue
+		}
+		j := i
+		for j < len(runes) && isWordRune(runes[j]) {
+			j++
+		}
+		word := strings.ToLower(string(runes[i:j]))
+		for _, term := range terms {
+			if matches(word, term) {
+				spans = append(spans, span{i, j})
+				break
+			}
+		}
+		i = j
+	}
+	if len(spans) == 0 {
+		return ""
+	}
+
+	from := spans[0].start - snippetRadius
+	if from < 0 {
+		from = 0
+	}
+	to := spans[0].end + snippetRadius
+	if to > len(runes) {
+		to = len(runes)
+	}
+
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString("…")
+	}
+	pos := from
+	for _, s := range spans {
+		if s.start < from || s.end > to {
+			continue
+		}
+		b.WriteString(string(runes[pos:s.start]))
+		b.WriteString(highlightOpen)
+		b.WriteString(string(runes[s.start:s.end]))
+		b.WriteString(highlightClose)
+		pos = s.end
+	}
+	b.WriteString(string(runes[pos:to]))
+	if to < len(runes) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r)
+}
@@ -0,0 +1,74 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Field weights shared by the Mongo text index and the in-memory ranking, so
+// both backends order results the same way.
+const (
+	NameWeight  = 10
+	TopicWeight = 2
+	GenreWeight = 5
+)
+
+// Tokenize lower-cases the query and splits it into unique words, dropping
+// punctuation so user input is never interpreted as a pattern.
+func Tokenize(query string) []string {
+	seen := map[string]bool{}
+	terms := []string{}
+	for _, word := range words(query) {
+		if !seen[word] {
+			seen[word] = true
+			terms = append(terms, word)
+		}
+	}
+	return terms
+}
+
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// matches reports whether a word matches a search term. Prefix matching stands
+// in for the stemming Mongo applies, so "run" finds "running".
+func matches(word string, term string) bool {
+	return strings.HasPrefix(word, term)
+}
+
+// MatchesAny reports whether any word of text matches one of the terms.
+func MatchesAny(text string, terms []string) bool {
+	for _, word := range words(text) {
+		for _, term := range terms {
+			if matches(word, term) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// FieldScore approximates Mongo's textScore for a single field: every
+// matching term adds the field weight, scaled down slightly for long fields.
+func FieldScore(text string, terms []string, weight float64) float64 {
+	fieldWords := words(text)
+	if len(fieldWords) == 0 {
+		return 0
+	}
+	score := 0.0
+	for _, term := range terms {
+		frequency := 0
+		for _, word := range fieldWords {
+			if matches(word, term) {
+				frequency++
+			}
+		}
+		if frequency > 0 {
+			score += weight * (0.5 + 0.5*float64(frequency)/float64(len(fieldWords)))
+		}
+	}
+	return score
+}